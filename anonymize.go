@@ -0,0 +1,280 @@
+package ismrmrd
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"reflect"
+)
+
+// AnonymizeAction says how an Anonymizer treats a header field.
+type AnonymizeAction int
+
+const (
+	AnonymizeKeep AnonymizeAction = iota
+	// AnonymizeRemove clears the field.
+	AnonymizeRemove
+	// AnonymizeHash replaces the field with a salted hash, so that equal
+	// values stay equal across files anonymized with the same salt.
+	AnonymizeHash
+	// AnonymizeShiftDate moves an XSD date by Anonymizer.DateShiftDays.
+	AnonymizeShiftDate
+	// AnonymizeReplaceUID replaces a DICOM UID with one derived from a
+	// salted hash under Anonymizer.UIDRoot.
+	AnonymizeReplaceUID
+)
+
+// DefaultAnonymizeActions covers the identifying fields of the subject,
+// study, measurement and acquisition system information.
+var DefaultAnonymizeActions = map[string]AnonymizeAction{
+	"subjectInformation.patientName":                                          AnonymizeRemove,
+	"subjectInformation.patientID":                                            AnonymizeHash,
+	"subjectInformation.patientBirthdate":                                     AnonymizeRemove,
+	"studyInformation.studyDate":                                              AnonymizeShiftDate,
+	"studyInformation.studyID":                                                AnonymizeHash,
+	"studyInformation.accessionNumber":                                        AnonymizeRemove,
+	"studyInformation.referringPhysicianName":                                 AnonymizeRemove,
+	"studyInformation.studyInstanceUID":                                       AnonymizeReplaceUID,
+	"measurementInformation.measurementID":                                    AnonymizeHash,
+	"measurementInformation.seriesDate":                                       AnonymizeShiftDate,
+	"measurementInformation.measurementDependency.measurementID":              AnonymizeHash,
+	"measurementInformation.seriesInstanceUIDRoot":                            AnonymizeReplaceUID,
+	"measurementInformation.frameOfReferenceUID":                              AnonymizeReplaceUID,
+	"measurementInformation.referencedImageSequence.referencedSOPInstanceUID": AnonymizeReplaceUID,
+	"acquisitionSystemInformation.institutionName":                            AnonymizeRemove,
+	"acquisitionSystemInformation.stationName":                                AnonymizeRemove,
+}
+
+// Anonymizer removes identifying information from headers.
+type Anonymizer struct {
+	// Actions maps field paths, written with XML element names and without
+	// indices (e.g. "subjectInformation.patientName"), to actions.
+	Actions map[string]AnonymizeAction
+	// Salt is mixed into hashes and replacement UIDs. It must be set, and
+	// kept secret, for the AnonymizeHash and AnonymizeReplaceUID actions:
+	// without it, hashes of short values such as patient IDs can be
+	// reversed by trying every candidate.
+	Salt string
+	// DateShiftDays is added to dates with the AnonymizeShiftDate action.
+	DateShiftDays int
+	// UIDRoot prefixes replacement UIDs.
+	UIDRoot string
+	// KeepExtensions keeps elements the header structs do not model.
+	// They are dropped by default, since they may hold anything.
+	KeepExtensions bool
+
+	// BlankPhysiologyTimeStamps and BlankTablePosition clear those fields
+	// of acquisition headers.
+	BlankPhysiologyTimeStamps bool
+	BlankTablePosition        bool
+}
+
+// NewAnonymizer returns an Anonymizer using DefaultAnonymizeActions and
+// the "2.25" UID root.
+func NewAnonymizer(salt string) *Anonymizer {
+	actions := make(map[string]AnonymizeAction, len(DefaultAnonymizeActions))
+	for path, action := range DefaultAnonymizeActions {
+		actions[path] = action
+	}
+	return &Anonymizer{Actions: actions, Salt: salt, UIDRoot: "2.25"}
+}
+
+// Header anonymizes head in place.
+func (a *Anonymizer) Header(head *IsmrmrdHeader) error {
+	if a.Salt == "" {
+		for path, action := range a.Actions {
+			if action == AnonymizeHash || action == AnonymizeReplaceUID {
+				return fmt.Errorf("%s: a salt is required to hash values", path)
+			}
+		}
+	}
+	return a.walk("", reflect.ValueOf(head).Elem())
+}
+
+func (a *Anonymizer) walk(path string, v reflect.Value) error {
+	switch v.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() {
+			return nil
+		}
+		// Removing an optional element drops it rather than zeroing it.
+		if action, ok := a.Actions[path]; ok && action == AnonymizeRemove {
+			v.Set(reflect.Zero(v.Type()))
+			return nil
+		}
+		return a.walk(path, v.Elem())
+	case reflect.Slice:
+		for i := 0; i < v.Len(); i++ {
+			if err := a.walk(path, v.Index(i)); err != nil {
+				return err
+			}
+		}
+		return nil
+	case reflect.Struct:
+		if f := extensionsField(v); f.IsValid() && !a.KeepExtensions {
+			f.Set(reflect.Zero(f.Type()))
+		}
+		for i := 0; i < v.NumField(); i++ {
+			name, _ := xmlField(v.Type(), i)
+			if name == "" {
+				continue
+			}
+			if path != "" {
+				name = path + "." + name
+			}
+			if err := a.walk(name, v.Field(i)); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	action, ok := a.Actions[path]
+	if !ok || action == AnonymizeKeep {
+		return nil
+	}
+	if err := a.apply(action, v); err != nil {
+		return fmt.Errorf("%s: %v", path, err)
+	}
+	return nil
+}
+
+func (a *Anonymizer) apply(action AnonymizeAction, v reflect.Value) error {
+	if action == AnonymizeRemove {
+		v.Set(reflect.Zero(v.Type()))
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		s := v.String()
+		if s == "" {
+			return nil
+		}
+		switch action {
+		case AnonymizeHash:
+			sum := a.hash(s)
+			v.SetString(hex.EncodeToString(sum[:8]))
+		case AnonymizeShiftDate:
+			d, err := parseXSDDate("value", s)
+			if err != nil {
+				return err
+			}
+			v.SetString(formatXSDDate(d.AddDate(0, 0, a.DateShiftDays)))
+		case AnonymizeReplaceUID:
+			sum := a.hash(s)
+			uid := a.UIDRoot + "." + new(big.Int).SetBytes(sum[:16]).String()
+			if len(uid) > 64 {
+				return fmt.Errorf("replacement UID %s is longer than 64 characters", uid)
+			}
+			v.SetString(uid)
+		default:
+			return fmt.Errorf("unknown action %d", action)
+		}
+		return nil
+
+	case reflect.Int, reflect.Int16, reflect.Int32, reflect.Int64:
+		if action != AnonymizeHash {
+			return fmt.Errorf("action %d does not apply to numbers", action)
+		}
+		if v.Int() != 0 {
+			sum := a.hash(fmt.Sprint(v.Int()))
+			v.SetInt(int64(binary.BigEndian.Uint32(sum[:4]) >> 1))
+		}
+		return nil
+	}
+
+	return fmt.Errorf("action %d does not apply to %s values", action, v.Kind())
+}
+
+func (a *Anonymizer) hash(s string) [sha256.Size]byte {
+	return sha256.Sum256([]byte(a.Salt + "\x00" + s))
+}
+
+// Acquisition clears the acquisition header fields selected by
+// BlankPhysiologyTimeStamps and BlankTablePosition.
+func (a *Anonymizer) Acquisition(h *AcquisitionHeader) {
+	if a.BlankPhysiologyTimeStamps {
+		h.PhysiologyTimeStamp = [ISMRMRD_PHYS_STAMPS]uint32{}
+	}
+	if a.BlankTablePosition {
+		h.PatientablePosition = [ISMRMRD_POSITION_LENGTH]float32{}
+	}
+}
+
+// Dataset writes an anonymized copy of src to dst: the anonymized XML
+// header, every acquisition with the fields selected by
+// BlankPhysiologyTimeStamps and BlankTablePosition cleared, and every
+// waveform, image series and array variable. Anonymizing a file in place is
+// not safe, since HDF5 does not erase the space an overwritten header
+// occupied and the original can be recovered from the file. Image
+// attributes are copied unchanged.
+func (a *Anonymizer) Dataset(dst, src *Dataset) error {
+	if err := dst.checkWritable(); err != nil {
+		return err
+	}
+
+	head, err := src.ReadHeader()
+	if err != nil {
+		return err
+	}
+	if err := a.Header(head); err != nil {
+		return err
+	}
+	if err := dst.WriteHeader(head); err != nil {
+		return err
+	}
+
+	err = src.forEachBatch(src.NumberOfAcquisitions(), func(start, count int) error {
+		acqs, err := src.ReadAcquisitions(start, count)
+		if err != nil {
+			return err
+		}
+		for i := range acqs {
+			a.Acquisition(&acqs[i].Head)
+		}
+		return dst.AppendAcquisitions(acqs)
+	})
+	if err != nil {
+		return err
+	}
+
+	contents, err := src.Contents()
+	if err != nil {
+		return err
+	}
+	for i := 0; i < contents.NumberOfWaveforms; i++ {
+		wav, err := src.ReadWaveform(i)
+		if err != nil {
+			return err
+		}
+		if err := dst.AppendWaveform(wav); err != nil {
+			return err
+		}
+	}
+	for _, series := range contents.Images {
+		for i := 0; i < series.Count; i++ {
+			img, err := src.ReadImage(series.Name, i)
+			if err != nil {
+				return err
+			}
+			if err := dst.AppendImage(series.Name, img); err != nil {
+				return err
+			}
+		}
+	}
+	for _, variable := range contents.Arrays {
+		for i := 0; i < variable.Count; i++ {
+			arr, err := src.ReadArray(variable.Name, i)
+			if err != nil {
+				return err
+			}
+			if err := dst.AppendArray(variable.Name, arr); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,74 @@
+// Command anonymize copies an ISMRMRD dataset to a new file, removing
+// identifying information from its XML header. It can also blank the
+// physiology time stamps and table position of every acquisition. The input
+// file is left untouched: HDF5 does not erase overwritten data, so a header
+// rewritten in place could still be recovered from the file.
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/naegelejd/go-ismrmrd"
+)
+
+func main() {
+	filename := flag.String("file", "", "input HDF5 file")
+	outname := flag.String("out", "", "output HDF5 file, which must not exist")
+	groupname := flag.String("group", "dataset", "dataset group within the files")
+	salt := flag.String("salt", "", "secret salt for hashed values and replacement UIDs (default random)")
+	shiftDays := flag.Int("shift-days", 0, "number of days to shift dates by")
+	uidRoot := flag.String("uid-root", "2.25", "root of replacement UIDs")
+	keepExt := flag.Bool("keep-extensions", false, "keep XML elements not defined by the schema")
+	blankPhysio := flag.Bool("blank-physio", false, "blank acquisition physiology time stamps")
+	blankTable := flag.Bool("blank-table", false, "blank acquisition patient table position")
+	flag.Parse()
+
+	if *filename == "" || *outname == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	// Reusing a salt keeps hashed IDs and UIDs consistent across files, so a
+	// generated one is printed for later runs.
+	if *salt == "" {
+		b := make([]byte, 16)
+		if _, err := rand.Read(b); err != nil {
+			log.Fatal(err)
+		}
+		*salt = hex.EncodeToString(b)
+		fmt.Fprintf(os.Stderr, "salt: %s\n", *salt)
+	}
+
+	src, err := ismrmrd.OpenDataset(*filename, *groupname, ismrmrd.ReadOnly)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer src.Close()
+
+	dst, err := ismrmrd.OpenDataset(*outname, *groupname,
+		ismrmrd.ReadWrite|ismrmrd.CreateIfMissing|ismrmrd.FailIfExists)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	a := ismrmrd.NewAnonymizer(*salt)
+	a.DateShiftDays = *shiftDays
+	a.UIDRoot = *uidRoot
+	a.KeepExtensions = *keepExt
+	a.BlankPhysiologyTimeStamps = *blankPhysio
+	a.BlankTablePosition = *blankTable
+
+	err = a.Dataset(dst, src)
+	if cerr := dst.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(*outname)
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,312 @@
+package ismrmrd
+
+import (
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestAnonymizeHeader(t *testing.T) {
+	head, err := Deserialize([]byte(testXML))
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := NewAnonymizer("salt")
+	a.DateShiftDays = 1
+	if err := a.Header(head); err != nil {
+		t.Fatal(err)
+	}
+
+	subject := head.SubjectInformation
+	if subject.PatientName != "" || subject.PatientBirthdate != "" {
+		t.Fatalf("patient name and birthdate were not removed (%+v)", subject)
+	}
+	if subject.PatientID == "" || subject.PatientID == patientID {
+		t.Fatalf("patient ID was not hashed (%q)", subject.PatientID)
+	}
+	if subject.PatientGender != patientGender {
+		t.Fatal("patient gender should have been kept")
+	}
+
+	study := head.StudyInformation
+	if study.StudyDate != "2015-01-01" {
+		t.Fatalf("study date shifted to %q", study.StudyDate)
+	}
+	if study.AccessionNumber != nil || study.ReferringPhysicianName != "" {
+		t.Fatalf("accession number and physician were not removed (%+v)", study)
+	}
+	if !strings.HasPrefix(study.StudyInstanceUID, "2.25.") || len(study.StudyInstanceUID) > 64 {
+		t.Fatalf("study instance UID replaced with %q", study.StudyInstanceUID)
+	}
+
+	measurement := head.MeasurementInformation
+	if measurement.SeriesDate != "2015-01-01" {
+		t.Fatalf("series date shifted to %q", measurement.SeriesDate)
+	}
+	if measurement.FrameOfReferenceUID != study.StudyInstanceUID {
+		t.Fatal("equal UIDs should be replaced with equal UIDs")
+	}
+	for _, uid := range measurement.ReferencedImageSequence.ReferencedSOPInstanceUID {
+		if uid == referencedImageSequence0 || uid == referencedImageSequence1 {
+			t.Fatalf("referenced SOP instance UID %q was not replaced", uid)
+		}
+	}
+
+	system := head.AcquisitionSystemInformation
+	if system.InstitutionName != "" || system.StationName != "" {
+		t.Fatalf("institution and station were not removed (%+v)", system)
+	}
+	if system.SystemVendor != systemVendor {
+		t.Fatal("system vendor should have been kept")
+	}
+
+	// The same salt must give the same results, so that anonymized files
+	// can still be matched up.
+	again, err := Deserialize([]byte(testXML))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := a.Header(again); err != nil {
+		t.Fatal(err)
+	}
+	if diffs := Diff(head, again); diffs != nil {
+		t.Fatalf("anonymization is not repeatable: %v", diffs)
+	}
+}
+
+func TestAnonymizeValidates(t *testing.T) {
+	head, err := Deserialize([]byte(testXML))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := NewAnonymizer("salt").Header(head); err != nil {
+		t.Fatal(err)
+	}
+	b, err := Serialize(head)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"patientName", "patientBirthdate", "accessionNumber", "institutionName"} {
+		if strings.Contains(string(b), "<"+name) {
+			t.Fatalf("removed %s is still in the document:\n%s", name, b)
+		}
+	}
+	errs, err := ValidateHeader(head)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if errs != nil {
+		t.Fatalf("anonymized header does not validate: %v", errs)
+	}
+}
+
+func TestAnonymizeRequiresSalt(t *testing.T) {
+	head, err := Deserialize([]byte(testXML))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := NewAnonymizer("").Header(head); err == nil {
+		t.Fatal("expected error hashing without a salt")
+	}
+	if head.SubjectInformation.PatientName != patientName {
+		t.Fatal("header was changed despite the error")
+	}
+
+	// A salt is not needed if nothing is hashed.
+	a := &Anonymizer{Actions: map[string]AnonymizeAction{"subjectInformation.patientName": AnonymizeRemove}}
+	if err := a.Header(head); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestAnonymizeActions(t *testing.T) {
+	head, err := Deserialize([]byte(testXML))
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := &Anonymizer{Salt: "salt", Actions: map[string]AnonymizeAction{
+		"subjectInformation.patientName": AnonymizeKeep,
+		"studyInformation.studyTime":     AnonymizeRemove,
+	}}
+	if err := a.Header(head); err != nil {
+		t.Fatal(err)
+	}
+	if head.SubjectInformation.PatientName != patientName || head.StudyInformation.StudyTime != "" {
+		t.Fatalf("custom actions were not applied (%+v %+v)", head.SubjectInformation, head.StudyInformation)
+	}
+
+	a.Actions = map[string]AnonymizeAction{"studyInformation.studyDescription": AnonymizeShiftDate}
+	if err := a.Header(head); err == nil {
+		t.Fatal("expected error shifting a value that is not a date")
+	}
+	a.Actions = map[string]AnonymizeAction{"subjectInformation.patientWeight_kg": AnonymizeReplaceUID}
+	if err := a.Header(head); err == nil {
+		t.Fatal("expected error replacing a number with a UID")
+	}
+}
+
+func TestAnonymizeShiftDateKeepsZone(t *testing.T) {
+	head := &IsmrmrdHeader{StudyInformation: &StudyInformation{StudyDate: "2014-12-31Z"}}
+	a := &Anonymizer{DateShiftDays: 1, Actions: map[string]AnonymizeAction{
+		"studyInformation.studyDate": AnonymizeShiftDate,
+	}}
+	if err := a.Header(head); err != nil {
+		t.Fatal(err)
+	}
+	if d := head.StudyInformation.StudyDate; d != "2015-01-01Z" {
+		t.Fatalf("study date shifted to %q", d)
+	}
+}
+
+func TestAnonymizeExtensions(t *testing.T) {
+	head, err := Deserialize([]byte(testXML))
+	if err != nil {
+		t.Fatal(err)
+	}
+	head.SubjectInformation.Extensions = &Extensions{Elements: []UnknownElement{{InnerXML: "secret"}}}
+
+	a := NewAnonymizer("salt")
+	a.KeepExtensions = true
+	if err := a.Header(head); err != nil {
+		t.Fatal(err)
+	}
+	if head.SubjectInformation.Extensions == nil {
+		t.Fatal("extensions should have been kept")
+	}
+	a.KeepExtensions = false
+	if err := a.Header(head); err != nil {
+		t.Fatal(err)
+	}
+	if head.SubjectInformation.Extensions != nil {
+		t.Fatal("extensions were not removed")
+	}
+}
+
+func TestAnonymizeAcquisition(t *testing.T) {
+	var h AcquisitionHeader
+	h.PhysiologyTimeStamp = [ISMRMRD_PHYS_STAMPS]uint32{1, 2, 3}
+	h.PatientablePosition = [ISMRMRD_POSITION_LENGTH]float32{4, 5, 6}
+
+	a := NewAnonymizer("")
+	a.Acquisition(&h)
+	if h.PhysiologyTimeStamp[0] != 1 || h.PatientablePosition[0] != 4 {
+		t.Fatal("acquisition header changed without being asked to")
+	}
+	a.BlankPhysiologyTimeStamps = true
+	a.BlankTablePosition = true
+	a.Acquisition(&h)
+	if h.PhysiologyTimeStamp != [ISMRMRD_PHYS_STAMPS]uint32{} || h.PatientablePosition != [ISMRMRD_POSITION_LENGTH]float32{} {
+		t.Fatalf("acquisition header was not blanked (%+v)", h)
+	}
+}
+
+const anonymized = "anonymized.h5"
+
+func TestAnonymizeDataset(t *testing.T) {
+	dset, err := Create(filename, groupname)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(filename)
+
+	if err := dset.WriteHeader(testHeader); err != nil {
+		t.Fatal(err)
+	}
+	var acqs []*Acquisition
+	for i := 0; i < 3; i++ {
+		acq := &Acquisition{}
+		acq.Head.ScanCounter = uint32(i)
+		acq.Head.NumberOfSamples = 2
+		acq.Head.ActiveChannels = 1
+		acq.Head.TrajectoryDimensions = 1
+		acq.Head.PhysiologyTimeStamp = [ISMRMRD_PHYS_STAMPS]uint32{1, 2, 3}
+		acq.Head.PatientablePosition = [ISMRMRD_POSITION_LENGTH]float32{4, 5, 6}
+		acq.Head.Position = [ISMRMRD_POSITION_LENGTH]float32{7, 8, 9}
+		acq.Traj = []float32{-0.5, 0.5}
+		acq.Data = []complex64{complex(float32(i), 1), complex(2, -float32(i))}
+		if err := dset.AppendAcquisition(acq); err != nil {
+			t.Fatal(err)
+		}
+		acqs = append(acqs, acq)
+	}
+	img, err := NewImage(ISMRMRD_FLOAT, 2, 1, 1, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	img.Data = []float32{1, 2}
+	if err := dset.AppendImage("image_0", img); err != nil {
+		t.Fatal(err)
+	}
+	if err := dset.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	src, err := OpenDataset(filename, groupname, ReadOnly)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer src.Close()
+	dst, err := Create(anonymized, groupname)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(anonymized)
+
+	a := NewAnonymizer("salt")
+	a.BlankPhysiologyTimeStamps = true
+	a.BlankTablePosition = true
+	if err := a.Dataset(dst, src); err != nil {
+		t.Fatal(err)
+	}
+	if err := dst.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if head, err := src.ReadHeader(); err != nil || head.SubjectInformation.PatientName != patientName {
+		t.Fatalf("source header was modified (%v)", err)
+	}
+
+	dset, err = OpenDataset(anonymized, groupname, ReadOnly)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dset.Close()
+
+	head, err := dset.ReadHeader()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if head.SubjectInformation.PatientName != "" || head.SubjectInformation.PatientID == patientID {
+		t.Fatalf("stored header was not anonymized (%+v)", head.SubjectInformation)
+	}
+	if head.Encoding[0].EncodedSpace != testHeader.Encoding[0].EncodedSpace {
+		t.Fatal("stored header lost its encoding")
+	}
+
+	if n := dset.NumberOfAcquisitions(); n != len(acqs) {
+		t.Fatalf("expected %d acquisitions, found %d", len(acqs), n)
+	}
+	got, err := dset.ReadAcquisitions(0, len(acqs))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, want := range acqs {
+		h := got[i].Head
+		if h.PhysiologyTimeStamp != [ISMRMRD_PHYS_STAMPS]uint32{} || h.PatientablePosition != [ISMRMRD_POSITION_LENGTH]float32{} {
+			t.Fatalf("acquisition %d was not blanked (%+v)", i, h)
+		}
+		h.PhysiologyTimeStamp = want.Head.PhysiologyTimeStamp
+		h.PatientablePosition = want.Head.PatientablePosition
+		if h != want.Head {
+			t.Fatalf("acquisition %d header changed beyond the blanked fields (%+v)", i, h)
+		}
+		if !reflect.DeepEqual(got[i].Traj, want.Traj) || !reflect.DeepEqual(got[i].Data, want.Data) {
+			t.Fatalf("acquisition %d data changed (%v %v)", i, got[i].Traj, got[i].Data)
+		}
+	}
+
+	if gotImg, err := dset.ReadImage("image_0", 0); err != nil || !reflect.DeepEqual(gotImg.Data, img.Data) {
+		t.Fatalf("image was not copied (%v)", err)
+	}
+}
@@ -0,0 +1,61 @@
+package ismrmrd
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+)
+
+// Sizes of the packed little-endian C header layouts used on the wire.
+const (
+	ACQUISITION_HEADER_SIZE = 340
+	IMAGE_HEADER_SIZE       = 198
+)
+
+// The C headers are declared with #pragma pack(2), so fields follow each
+// other without alignment padding. encoding/binary also ignores alignment
+// and encodes fields in declaration order, so the Go structs encode to the C
+// layout as long as their fields stay in C order.
+
+// MarshalBinary encodes h in the 340-byte ISMRMRD_AcquisitionHeader layout.
+func (h *AcquisitionHeader) MarshalBinary() ([]byte, error) {
+	return marshalHeader(h, ACQUISITION_HEADER_SIZE)
+}
+
+// UnmarshalBinary decodes h from the 340-byte ISMRMRD_AcquisitionHeader
+// layout.
+func (h *AcquisitionHeader) UnmarshalBinary(data []byte) error {
+	return unmarshalHeader(data, h, ACQUISITION_HEADER_SIZE)
+}
+
+// MarshalBinary encodes h in the 198-byte ISMRMRD_ImageHeader layout.
+// AttributeStringLen occupies the last four bytes, after UserFloat, as in
+// the C header; callers sending an attribute string should keep it equal to
+// the string's length, which the MRD stream also carries separately.
+func (h *ImageHeader) MarshalBinary() ([]byte, error) {
+	return marshalHeader(h, IMAGE_HEADER_SIZE)
+}
+
+// UnmarshalBinary decodes h from the 198-byte ISMRMRD_ImageHeader layout.
+func (h *ImageHeader) UnmarshalBinary(data []byte) error {
+	return unmarshalHeader(data, h, IMAGE_HEADER_SIZE)
+}
+
+func marshalHeader(h interface{}, size int) ([]byte, error) {
+	var buf bytes.Buffer
+	buf.Grow(size)
+	if err := binary.Write(&buf, byteOrder, h); err != nil {
+		return nil, err
+	}
+	if buf.Len() != size {
+		return nil, fmt.Errorf("%T encoded to %d bytes, expected %d", h, buf.Len(), size)
+	}
+	return buf.Bytes(), nil
+}
+
+func unmarshalHeader(data []byte, h interface{}, size int) error {
+	if len(data) != size {
+		return fmt.Errorf("%T requires %d bytes, got %d", h, size, len(data))
+	}
+	return binary.Read(bytes.NewReader(data), byteOrder, h)
+}
@@ -0,0 +1,172 @@
+package ismrmrd
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+)
+
+// put writes v little-endian at offset off of b.
+func put(b []byte, off int, v interface{}) {
+	var buf bytes.Buffer
+	if err := binary.Write(&buf, binary.LittleEndian, v); err != nil {
+		panic(err)
+	}
+	copy(b[off:], buf.Bytes())
+}
+
+func TestAcquisitionHeaderMarshalBinary(t *testing.T) {
+	var h AcquisitionHeader
+	h.Version = 0x0102
+	h.Flags = 0x0102030405060708
+	h.MeasurementUID = 0x11121314
+	h.ScanCounter = 0x21222324
+	h.AcquisitionTimeStamp = 0x31323334
+	h.PhysiologyTimeStamp = [3]uint32{0x41, 0x42, 0x43}
+	h.NumberOfSamples = 0x51
+	h.AvailableChannels = 0x52
+	h.ActiveChannels = 0x53
+	h.ChannelMask[0] = 0x61
+	h.ChannelMask[15] = 0x6f
+	h.DiscardPre = 0x71
+	h.DiscardPost = 0x72
+	h.CenterSample = 0x73
+	h.EncodingSpaceRef = 0x74
+	h.TrajectoryDimensions = 0x75
+	h.SampleTimeUs = 2.5
+	h.Position = [3]float32{1, 2, 3}
+	h.ReadDirection = [3]float32{4, 5, 6}
+	h.PhaseDirection = [3]float32{7, 8, 9}
+	h.SliceDirection = [3]float32{10, 11, 12}
+	h.PatientablePosition = [3]float32{13, 14, 15}
+	h.Idx.KSpaceEncodeStep1 = 0x81
+	h.Idx.Segment = 0x89
+	h.Idx.User[7] = 0x8f
+	h.UserInt[0] = -1
+	h.UserFloat32[7] = 0.25
+
+	// Offsets from the packed C ISMRMRD_AcquisitionHeader.
+	want := make([]byte, 340)
+	put(want, 0, h.Version)
+	put(want, 2, h.Flags)
+	put(want, 10, h.MeasurementUID)
+	put(want, 14, h.ScanCounter)
+	put(want, 18, h.AcquisitionTimeStamp)
+	put(want, 22, h.PhysiologyTimeStamp)
+	put(want, 34, h.NumberOfSamples)
+	put(want, 36, h.AvailableChannels)
+	put(want, 38, h.ActiveChannels)
+	put(want, 40, h.ChannelMask)
+	put(want, 168, h.DiscardPre)
+	put(want, 170, h.DiscardPost)
+	put(want, 172, h.CenterSample)
+	put(want, 174, h.EncodingSpaceRef)
+	put(want, 176, h.TrajectoryDimensions)
+	put(want, 178, h.SampleTimeUs)
+	put(want, 182, h.Position)
+	put(want, 194, h.ReadDirection)
+	put(want, 206, h.PhaseDirection)
+	put(want, 218, h.SliceDirection)
+	put(want, 230, h.PatientablePosition)
+	put(want, 242, h.Idx.KSpaceEncodeStep1)
+	put(want, 258, h.Idx.Segment)
+	put(want, 260, h.Idx.User)
+	put(want, 276, h.UserInt)
+	put(want, 308, h.UserFloat32)
+
+	got, err := h.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Fatalf("encoding does not match the C layout:\n got %x\nwant %x", got, want)
+	}
+
+	var h2 AcquisitionHeader
+	if err := h2.UnmarshalBinary(want); err != nil {
+		t.Fatal(err)
+	}
+	if h2 != h {
+		t.Fatalf("decoded header does not match (%+v)", h2)
+	}
+
+	if err := h2.UnmarshalBinary(want[:339]); err == nil {
+		t.Fatal("expected error decoding a short header")
+	}
+}
+
+func TestImageHeaderMarshalBinary(t *testing.T) {
+	var h ImageHeader
+	h.Version = 0x0102
+	h.DataType = ISMRMRD_CXFLOAT
+	h.Flags = 0x0102030405060708
+	h.MeasurementUID = 0x11121314
+	h.MatrixSize = [3]uint16{256, 128, 1}
+	h.FieldOfView = [3]float32{300, 150, 5}
+	h.Channels = 8
+	h.Position = [3]float32{1, 2, 3}
+	h.ReadDirection = [3]float32{4, 5, 6}
+	h.PhaseDirection = [3]float32{7, 8, 9}
+	h.SliceDirection = [3]float32{10, 11, 12}
+	h.PatientTablePosition = [3]float32{13, 14, 15}
+	h.Average = 0x21
+	h.Slice = 0x22
+	h.Contrast = 0x23
+	h.Phase = 0x24
+	h.Repetition = 0x25
+	h.Set = 0x26
+	h.AcquisitionTimeStamp = 0x31323334
+	h.PhysiologyTimeStamp = [3]uint32{0x41, 0x42, 0x43}
+	h.ImageType = ISMRMRD_IMTYPE_MAGNITUDE
+	h.ImageIndex = 0x51
+	h.ImageSeriesIndex = 0x52
+	h.UserInt[7] = -7
+	h.UserFloat[0] = 0.5
+	h.AttributeStringLen = 0x61626364
+
+	// Offsets from the packed C ISMRMRD_ImageHeader, whose last member is
+	// attribute_string_len.
+	want := make([]byte, 198)
+	put(want, 0, h.Version)
+	put(want, 2, h.DataType)
+	put(want, 4, h.Flags)
+	put(want, 12, h.MeasurementUID)
+	put(want, 16, h.MatrixSize)
+	put(want, 22, h.FieldOfView)
+	put(want, 34, h.Channels)
+	put(want, 36, h.Position)
+	put(want, 48, h.ReadDirection)
+	put(want, 60, h.PhaseDirection)
+	put(want, 72, h.SliceDirection)
+	put(want, 84, h.PatientTablePosition)
+	put(want, 96, h.Average)
+	put(want, 98, h.Slice)
+	put(want, 100, h.Contrast)
+	put(want, 102, h.Phase)
+	put(want, 104, h.Repetition)
+	put(want, 106, h.Set)
+	put(want, 108, h.AcquisitionTimeStamp)
+	put(want, 112, h.PhysiologyTimeStamp)
+	put(want, 124, h.ImageType)
+	put(want, 126, h.ImageIndex)
+	put(want, 128, h.ImageSeriesIndex)
+	put(want, 130, h.UserInt)
+	put(want, 162, h.UserFloat)
+	put(want, 194, h.AttributeStringLen)
+
+	got, err := h.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Fatalf("encoding does not match the C layout:\n got %x\nwant %x", got, want)
+	}
+
+	var h2 ImageHeader
+	if err := h2.UnmarshalBinary(want); err != nil {
+		t.Fatal(err)
+	}
+	if h2 != h {
+		t.Fatalf("decoded header does not match (%+v)", h2)
+	}
+}
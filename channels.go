@@ -0,0 +1,112 @@
+package ismrmrd
+
+import (
+	"fmt"
+	"math/bits"
+)
+
+// ISMRMRD_MAX_CHANNELS is the number of channels ChannelMask can describe.
+const ISMRMRD_MAX_CHANNELS = 64 * ISMRMRD_CHANNEL_MASKS
+
+func channelBit(ch uint16) (word int, mask uint64, ok bool) {
+	if int(ch) >= ISMRMRD_MAX_CHANNELS {
+		return 0, 0, false
+	}
+	return int(ch / 64), 1 << (ch % 64), true
+}
+
+// IsChannelActive reports whether channel ch is set in the channel mask.
+func (h *AcquisitionHeader) IsChannelActive(ch uint16) bool {
+	word, mask, ok := channelBit(ch)
+	return ok && h.ChannelMask[word]&mask != 0
+}
+
+// SetChannelActive sets channel ch in the channel mask. Channels beyond
+// ISMRMRD_MAX_CHANNELS are ignored.
+func (h *AcquisitionHeader) SetChannelActive(ch uint16) {
+	if word, mask, ok := channelBit(ch); ok {
+		h.ChannelMask[word] |= mask
+	}
+}
+
+// SetChannelNotActive clears channel ch in the channel mask.
+func (h *AcquisitionHeader) SetChannelNotActive(ch uint16) {
+	if word, mask, ok := channelBit(ch); ok {
+		h.ChannelMask[word] &^= mask
+	}
+}
+
+// SetAllChannelsNotActive clears the channel mask.
+func (h *AcquisitionHeader) SetAllChannelsNotActive() {
+	h.ChannelMask = [ISMRMRD_CHANNEL_MASKS]uint64{}
+}
+
+// ActiveChannelIndices lists the channels set in the channel mask in
+// ascending order. The rows of Acquisition.Data follow the same order.
+func (h *AcquisitionHeader) ActiveChannelIndices() []uint16 {
+	var chans []uint16
+	for word, m := range h.ChannelMask {
+		for m != 0 {
+			b := bits.TrailingZeros64(m)
+			chans = append(chans, uint16(word*64+b))
+			m &^= 1 << uint(b)
+		}
+	}
+	return chans
+}
+
+// ValidateChannelMask checks that the channel mask sets exactly
+// ActiveChannels channels, none of them at or beyond AvailableChannels
+// when that is known.
+func (h *AcquisitionHeader) ValidateChannelMask() error {
+	n := 0
+	for _, m := range h.ChannelMask {
+		n += bits.OnesCount64(m)
+	}
+	if n != int(h.ActiveChannels) {
+		return fmt.Errorf("channel mask has %d channels set, expected %d active channels", n, h.ActiveChannels)
+	}
+	if h.AvailableChannels == 0 {
+		return nil
+	}
+	for _, ch := range h.ActiveChannelIndices() {
+		if ch >= h.AvailableChannels {
+			return fmt.Errorf("channel %d is active but only %d channels are available", ch, h.AvailableChannels)
+		}
+	}
+	return nil
+}
+
+// SelectChannels keeps only the listed channels, removing the other rows
+// from Data and clearing them from the channel mask. Every listed channel
+// must be active, and the mask must be consistent with ActiveChannels.
+func (acq *Acquisition) SelectChannels(channels ...uint16) error {
+	h := &acq.Head
+	if err := h.ValidateChannelMask(); err != nil {
+		return err
+	}
+	ns := int(h.NumberOfSamples)
+	if len(acq.Data) != ns*int(h.ActiveChannels) {
+		return fmt.Errorf("acquisition has %d samples, expected %d", len(acq.Data), ns*int(h.ActiveChannels))
+	}
+
+	var keep AcquisitionHeader
+	for _, ch := range channels {
+		if !h.IsChannelActive(ch) {
+			return fmt.Errorf("channel %d is not active", ch)
+		}
+		keep.SetChannelActive(ch)
+	}
+
+	data := make([]complex64, 0, ns*len(channels))
+	for row, ch := range h.ActiveChannelIndices() {
+		if keep.IsChannelActive(ch) {
+			data = append(data, acq.Data[row*ns:(row+1)*ns]...)
+		}
+	}
+
+	acq.Data = data
+	h.ChannelMask = keep.ChannelMask
+	h.ActiveChannels = uint16(len(keep.ActiveChannelIndices()))
+	return nil
+}
@@ -0,0 +1,71 @@
+package ismrmrd
+
+import "testing"
+
+func TestChannelMask(t *testing.T) {
+	var h AcquisitionHeader
+	h.AvailableChannels = 128
+	h.SetChannelActive(0)
+	h.SetChannelActive(65)
+	h.SetChannelActive(127)
+	h.SetChannelActive(ISMRMRD_MAX_CHANNELS)
+
+	if h.ChannelMask[0] != 1 || h.ChannelMask[1] != 1<<1|1<<63 {
+		t.Fatalf("unexpected channel mask %#x", h.ChannelMask[:2])
+	}
+	chans := h.ActiveChannelIndices()
+	if len(chans) != 3 || chans[0] != 0 || chans[1] != 65 || chans[2] != 127 {
+		t.Fatalf("unexpected active channels %v", chans)
+	}
+
+	if err := h.ValidateChannelMask(); err == nil {
+		t.Fatal("expected error validating a mask that disagrees with ActiveChannels")
+	}
+	h.ActiveChannels = 3
+	if err := h.ValidateChannelMask(); err != nil {
+		t.Fatal(err)
+	}
+	h.AvailableChannels = 100
+	if err := h.ValidateChannelMask(); err == nil {
+		t.Fatal("expected error validating a channel beyond AvailableChannels")
+	}
+
+	h.SetChannelNotActive(65)
+	if h.IsChannelActive(65) || !h.IsChannelActive(127) {
+		t.Fatal("SetChannelNotActive cleared the wrong channel")
+	}
+	h.SetAllChannelsNotActive()
+	if len(h.ActiveChannelIndices()) != 0 {
+		t.Fatal("channels remain after SetAllChannelsNotActive")
+	}
+}
+
+func TestSelectChannels(t *testing.T) {
+	acq := &Acquisition{}
+	acq.Head.NumberOfSamples = 2
+	acq.Head.ActiveChannels = 3
+	acq.Head.SetChannelActive(1)
+	acq.Head.SetChannelActive(4)
+	acq.Head.SetChannelActive(6)
+	acq.Data = []complex64{1, 1, 4, 4, 6, 6}
+
+	if err := acq.SelectChannels(6, 1); err != nil {
+		t.Fatal(err)
+	}
+	if acq.Head.ActiveChannels != 2 || acq.Head.IsChannelActive(4) {
+		t.Fatalf("unexpected header after selection (%+v)", acq.Head)
+	}
+	want := []complex64{1, 1, 6, 6}
+	if len(acq.Data) != len(want) {
+		t.Fatalf("selected data %v, expected %v", acq.Data, want)
+	}
+	for i := range want {
+		if acq.Data[i] != want[i] {
+			t.Fatalf("selected data %v, expected %v", acq.Data, want)
+		}
+	}
+
+	if err := acq.SelectChannels(4); err == nil {
+		t.Fatal("expected error selecting an inactive channel")
+	}
+}
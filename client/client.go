@@ -0,0 +1,255 @@
+// Package client sends ISMRMRD datasets to a reconstruction server, such as
+// Gadgetron, over the MRD streaming protocol and collects the results.
+package client
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"sync"
+
+	"github.com/naegelejd/go-ismrmrd"
+)
+
+// Sink receives the images and arrays returned by the server.
+type Sink interface {
+	Image(img *ismrmrd.Image) error
+	Array(arr *ismrmrd.NDArray) error
+}
+
+// DatasetSink appends returned images to Dataset, one series per
+// ImageSeriesIndex as given by ismrmrd.ImageSeriesPath, and arrays to
+// ArrayPath.
+type DatasetSink struct {
+	Dataset   *ismrmrd.Dataset
+	ArrayPath string
+}
+
+func (s *DatasetSink) Image(img *ismrmrd.Image) error {
+	return s.Dataset.AppendImage(ismrmrd.ImageSeriesPath(img), img)
+}
+
+func (s *DatasetSink) Array(arr *ismrmrd.NDArray) error {
+	return s.Dataset.AppendArray(s.ArrayPath, arr)
+}
+
+// Client is a connection to a reconstruction server. A session consists of
+// a config, the header, the acquisitions and a close message; results are
+// read concurrently with Receive.
+type Client struct {
+	conn net.Conn
+	buf  *bufio.Writer
+	w    *ismrmrd.StreamWriter
+	r    *ismrmrd.StreamReader
+}
+
+// Dial connects to the server at address, e.g. "localhost:9002".
+func Dial(address string) (*Client, error) {
+	conn, err := net.Dial("tcp", address)
+	if err != nil {
+		return nil, err
+	}
+	return NewClient(conn), nil
+}
+
+// NewClient returns a client using an established connection.
+func NewClient(conn net.Conn) *Client {
+	buf := bufio.NewWriter(conn)
+	return &Client{
+		conn: conn,
+		buf:  buf,
+		w:    ismrmrd.NewStreamWriter(buf),
+		r:    ismrmrd.NewStreamReader(bufio.NewReader(conn)),
+	}
+}
+
+// Close closes the connection.
+func (c *Client) Close() error {
+	return c.conn.Close()
+}
+
+// SendConfigFile asks the server to load a named configuration.
+func (c *Client) SendConfigFile(name string) error {
+	if err := c.w.WriteConfigFile(name); err != nil {
+		return err
+	}
+	return c.buf.Flush()
+}
+
+// SendConfigText sends a complete configuration document.
+func (c *Client) SendConfigText(config string) error {
+	if err := c.w.WriteConfigText(config); err != nil {
+		return err
+	}
+	return c.buf.Flush()
+}
+
+// SendHeader sends the XML header.
+func (c *Client) SendHeader(head *ismrmrd.IsmrmrdHeader) error {
+	if err := c.w.WriteHeader(head); err != nil {
+		return err
+	}
+	return c.buf.Flush()
+}
+
+// SendXMLHeader sends a raw XML header.
+func (c *Client) SendXMLHeader(xml string) error {
+	if err := c.w.WriteXMLHeader(xml); err != nil {
+		return err
+	}
+	return c.buf.Flush()
+}
+
+// SendAcquisition sends a single acquisition. Acquisitions are buffered
+// until the next SendClose.
+func (c *Client) SendAcquisition(acq *ismrmrd.Acquisition) error {
+	return c.w.WriteAcquisition(acq)
+}
+
+// SendDataset sends the XML header and every acquisition stored in dset.
+func (c *Client) SendDataset(dset *ismrmrd.Dataset) error {
+	xml, err := dset.ReadXMLHeader()
+	if err != nil {
+		return err
+	}
+	if err := c.SendXMLHeader(xml); err != nil {
+		return err
+	}
+
+	err = dset.EachAcquisition(func(i int, acq *ismrmrd.Acquisition) error {
+		return c.SendAcquisition(acq)
+	})
+	if err != nil {
+		return err
+	}
+
+	return c.buf.Flush()
+}
+
+// SendClose tells the server no more data will be sent.
+func (c *Client) SendClose() error {
+	if err := c.w.WriteClose(); err != nil {
+		return err
+	}
+	return c.buf.Flush()
+}
+
+// Receive hands every image and array returned by the server to sink until
+// the server sends its close message.
+func (c *Client) Receive(sink Sink) error {
+	for {
+		msg, err := c.r.ReadMessage()
+		if err != nil {
+			return err
+		}
+
+		switch msg.ID {
+		case ismrmrd.MRD_MESSAGE_CLOSE:
+			return nil
+		case ismrmrd.MRD_MESSAGE_ISMRMRD_IMAGE:
+			err = sink.Image(msg.Image)
+		case ismrmrd.MRD_MESSAGE_ISMRMRD_NDARRAY:
+			err = sink.Array(msg.Array)
+		case ismrmrd.MRD_MESSAGE_TEXT:
+		default:
+			err = fmt.Errorf("unexpected message %d from server", msg.ID)
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// Process runs a complete session: it sends the header and acquisitions of
+// in followed by a close message, while storing the results in sink. The
+// config must already have been sent. Results are received concurrently
+// with reading in, but since libhdf5 is not thread-safe they are held in
+// memory until every acquisition has been sent; only then is sink called,
+// from a goroutine other than the caller's. If sending or receiving fails,
+// the connection is closed so that the other side stops too, and the first
+// error is returned.
+func (c *Client) Process(in *ismrmrd.Dataset, sink Sink) error {
+	var once sync.Once
+	var first error
+	fail := func(err error) {
+		once.Do(func() {
+			first = err
+			c.conn.Close()
+		})
+	}
+
+	q := &queue{sink: sink}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		if err := c.Receive(q); err != nil {
+			fail(err)
+		}
+	}()
+
+	err := c.SendDataset(in)
+	if err == nil {
+		err = c.SendClose()
+	}
+	if err == nil {
+		err = q.release()
+	}
+	if err != nil {
+		fail(err)
+	}
+	<-done
+
+	return first
+}
+
+// queue holds results until release is called and passes them to sink
+// afterwards, so that sink never runs while the input is being read.
+type queue struct {
+	mu       sync.Mutex
+	sink     Sink
+	items    []interface{}
+	released bool
+}
+
+func (q *queue) Image(img *ismrmrd.Image) error {
+	return q.put(img)
+}
+
+func (q *queue) Array(arr *ismrmrd.NDArray) error {
+	return q.put(arr)
+}
+
+func (q *queue) put(item interface{}) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if !q.released {
+		q.items = append(q.items, item)
+		return nil
+	}
+	return q.deliver(item)
+}
+
+// release hands the queued results to sink.
+func (q *queue) release() error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.released = true
+	items := q.items
+	q.items = nil
+	for _, item := range items {
+		if err := q.deliver(item); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (q *queue) deliver(item interface{}) error {
+	switch v := item.(type) {
+	case *ismrmrd.Image:
+		return q.sink.Image(v)
+	case *ismrmrd.NDArray:
+		return q.sink.Array(v)
+	}
+	return nil
+}
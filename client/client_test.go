@@ -0,0 +1,270 @@
+package client
+
+import (
+	"errors"
+	"net"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/naegelejd/go-ismrmrd"
+	"github.com/naegelejd/go-ismrmrd/server"
+)
+
+const emptyHeader = `<ismrmrdHeader xmlns="http://www.ismrm.org/ISMRMRD"></ismrmrdHeader>`
+
+type memorySink struct {
+	images []*ismrmrd.Image
+	arrays []*ismrmrd.NDArray
+}
+
+func (s *memorySink) Image(img *ismrmrd.Image) error {
+	s.images = append(s.images, img)
+	return nil
+}
+
+func (s *memorySink) Array(arr *ismrmrd.NDArray) error {
+	s.arrays = append(s.arrays, arr)
+	return nil
+}
+
+// serve stands in for a reconstruction server: it returns one image per
+// acquisition and one array once the client closes the session.
+func serve(t *testing.T, l net.Listener, config chan<- string) {
+	conn, err := l.Accept()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer conn.Close()
+
+	r := ismrmrd.NewStreamReader(conn)
+	w := ismrmrd.NewStreamWriter(conn)
+	for {
+		msg, err := r.ReadMessage()
+		if err != nil {
+			t.Error(err)
+			return
+		}
+
+		switch msg.ID {
+		case ismrmrd.MRD_MESSAGE_CONFIG_FILE:
+			config <- msg.Text
+		case ismrmrd.MRD_MESSAGE_HEADER:
+			if _, err := msg.Header(); err != nil {
+				t.Error(err)
+			}
+		case ismrmrd.MRD_MESSAGE_ISMRMRD_ACQUISITION:
+			img, _ := ismrmrd.NewImage(ismrmrd.ISMRMRD_FLOAT, 2, 2, 1, 1)
+			img.Head.ImageIndex = uint16(msg.Acquisition.Head.ScanCounter)
+			if err := w.WriteImage(img); err != nil {
+				t.Error(err)
+			}
+		case ismrmrd.MRD_MESSAGE_CLOSE:
+			arr, _ := ismrmrd.NewNDArray(ismrmrd.ISMRMRD_CXFLOAT, 2)
+			if err := w.WriteArray(arr); err != nil {
+				t.Error(err)
+			}
+			if err := w.WriteClose(); err != nil {
+				t.Error(err)
+			}
+			return
+		}
+	}
+}
+
+func TestClientSession(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	config := make(chan string, 1)
+	go serve(t, l, config)
+
+	c, err := Dial(l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	if err := c.SendConfigFile("default.xml"); err != nil {
+		t.Fatal(err)
+	}
+	if got := <-config; got != "default.xml" {
+		t.Fatalf("server received config %q", got)
+	}
+
+	sink := &memorySink{}
+	done := make(chan error, 1)
+	go func() {
+		done <- c.Receive(sink)
+	}()
+
+	if err := c.SendXMLHeader(emptyHeader); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		acq := &ismrmrd.Acquisition{}
+		acq.Head.ScanCounter = uint32(i)
+		if err := c.SendAcquisition(acq); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := c.SendClose(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	if len(sink.images) != 3 || sink.images[2].Head.ImageIndex != 2 {
+		t.Fatalf("expected 3 images, received %d", len(sink.images))
+	}
+	if len(sink.arrays) != 1 {
+		t.Fatalf("expected 1 array, received %d", len(sink.arrays))
+	}
+}
+
+// startServer serves h on a loopback listener and returns its address.
+func startServer(t *testing.T, h server.HandlerFunc) (*server.Server, string) {
+	s := &server.Server{
+		NewChain: func(c *server.Conn) ([]server.Handler, error) {
+			return []server.Handler{h}, nil
+		},
+	}
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go s.Serve(l)
+	return s, l.Addr().String()
+}
+
+// reconstruct returns one image per acquisition, alternating between two
+// series.
+func reconstruct(c *server.Conn, item interface{}, next server.Emit) error {
+	acq, ok := item.(*ismrmrd.Acquisition)
+	if !ok {
+		return next(item)
+	}
+	img, err := ismrmrd.NewImage(ismrmrd.ISMRMRD_FLOAT, 2, 2, 1, 1)
+	if err != nil {
+		return err
+	}
+	img.Head.ImageIndex = uint16(acq.Head.ScanCounter)
+	img.Head.ImageSeriesIndex = uint16(acq.Head.ScanCounter % 2)
+	return next(img)
+}
+
+// createInput writes a dataset holding n acquisitions of the given number
+// of samples to filename.
+func createInput(t *testing.T, filename string, n, samples int) *ismrmrd.Dataset {
+	dset, err := ismrmrd.Create(filename, "dataset")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := dset.WriteXMLHeader(emptyHeader); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < n; i++ {
+		acq := &ismrmrd.Acquisition{}
+		acq.Head.ScanCounter = uint32(i)
+		acq.Head.NumberOfSamples = uint16(samples)
+		acq.Head.ActiveChannels = 1
+		acq.Data = make([]complex64, samples)
+		if err := dset.AppendAcquisition(acq); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dset
+}
+
+// process runs Process against h and fails the test if it does not return
+// in time.
+func process(t *testing.T, h server.HandlerFunc, in *ismrmrd.Dataset, sink Sink) error {
+	s, addr := startServer(t, h)
+	defer s.Close()
+
+	c, err := Dial(addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	done := make(chan error, 1)
+	go func() {
+		done <- c.Process(in, sink)
+	}()
+	select {
+	case err := <-done:
+		return err
+	case <-time.After(10 * time.Second):
+		t.Fatal("Process did not return")
+	}
+	return nil
+}
+
+func TestProcess(t *testing.T) {
+	in := createInput(t, "input.h5", 5, 4)
+	defer os.Remove("input.h5")
+	defer in.Close()
+
+	out, err := ismrmrd.Create("output.h5", "dataset")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove("output.h5")
+	defer out.Close()
+
+	if err := process(t, reconstruct, in, &DatasetSink{Dataset: out, ArrayPath: "arrays"}); err != nil {
+		t.Fatal(err)
+	}
+	if n0, n1 := out.NumberOfImages("image_0"), out.NumberOfImages("image_1"); n0 != 3 || n1 != 2 {
+		t.Fatalf("expected 3 and 2 images per series, stored %d and %d", n0, n1)
+	}
+	img, err := out.ReadImage("image_0", 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if img.Head.ImageIndex != 4 {
+		t.Fatalf("expected image 4, read %d", img.Head.ImageIndex)
+	}
+}
+
+type failingSink struct {
+	err error
+}
+
+func (s *failingSink) Image(img *ismrmrd.Image) error   { return s.err }
+func (s *failingSink) Array(arr *ismrmrd.NDArray) error { return s.err }
+
+func TestProcessSinkError(t *testing.T) {
+	in := createInput(t, "input.h5", 5, 4)
+	defer os.Remove("input.h5")
+	defer in.Close()
+
+	sinkErr := errors.New("sink full")
+	if err := process(t, reconstruct, in, &failingSink{sinkErr}); err != sinkErr {
+		t.Fatalf("expected the sink error, got %v", err)
+	}
+}
+
+func TestProcessReceiveError(t *testing.T) {
+	// Far more data than the socket buffers hold, so that sending blocks
+	// once nothing reads the connection any more.
+	in := createInput(t, "input.h5", 512, 4096)
+	defer os.Remove("input.h5")
+	defer in.Close()
+
+	// Echoing acquisitions back is a protocol error on the client side.
+	echo := func(c *server.Conn, item interface{}, next server.Emit) error {
+		return next(item)
+	}
+	err := process(t, echo, in, &memorySink{})
+	if err == nil || !strings.Contains(err.Error(), "unexpected message") {
+		t.Fatalf("expected an unexpected message error, got %v", err)
+	}
+}
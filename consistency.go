@@ -0,0 +1,94 @@
+package ismrmrd
+
+import "fmt"
+
+// ConsistencyError describes an acquisition whose header disagrees with
+// the XML header or with its own data. Acquisition is the index of the
+// acquisition in the dataset.
+type ConsistencyError struct {
+	Acquisition int
+	Message     string
+}
+
+func (e ConsistencyError) Error() string {
+	return fmt.Sprintf("acquisition %d: %s", e.Acquisition, e.Message)
+}
+
+// CheckAcquisition checks acquisition n against the XML header: its
+// encoding space reference, encoding counters, channel count, and the
+// lengths of its data and trajectory.
+func CheckAcquisition(head *IsmrmrdHeader, n int, acq *Acquisition) []ConsistencyError {
+	var errs []ConsistencyError
+	fail := func(format string, args ...interface{}) {
+		errs = append(errs, ConsistencyError{n, fmt.Sprintf(format, args...)})
+	}
+	h := &acq.Head
+
+	if int(h.EncodingSpaceRef) >= len(head.Encoding) {
+		fail("encoding space %d does not exist, the header has %d encodings", h.EncodingSpaceRef, len(head.Encoding))
+	} else {
+		limits := &head.Encoding[h.EncodingSpaceRef].EncodingLimits
+		counters := []struct {
+			name  string
+			value uint16
+			limit *Limit
+		}{
+			{"kspace_encode_step_1", h.Idx.KSpaceEncodeStep1, limits.KSpaceEncodingStep1},
+			{"kspace_encode_step_2", h.Idx.KSpaceEncodeStep2, limits.KSpaceEncodingStep2},
+			{"average", h.Idx.Average, limits.Average},
+			{"slice", h.Idx.Slice, limits.Slice},
+			{"contrast", h.Idx.Contrast, limits.Contrast},
+			{"phase", h.Idx.Phase, limits.Phase},
+			{"repetition", h.Idx.Repetition, limits.Repetition},
+			{"set", h.Idx.Set, limits.Set},
+			{"segment", h.Idx.Segment, limits.Segment},
+			{"user_0", h.Idx.User[0], limits.User0},
+			{"user_1", h.Idx.User[1], limits.User1},
+			{"user_2", h.Idx.User[2], limits.User2},
+			{"user_3", h.Idx.User[3], limits.User3},
+			{"user_4", h.Idx.User[4], limits.User4},
+			{"user_5", h.Idx.User[5], limits.User5},
+			{"user_6", h.Idx.User[6], limits.User6},
+			{"user_7", h.Idx.User[7], limits.User7},
+		}
+		for _, c := range counters {
+			if c.limit != nil && (c.value < c.limit.Minimum || c.value > c.limit.Maximum) {
+				fail("%s counter %d is outside the encoding limits [%d, %d]", c.name, c.value, c.limit.Minimum, c.limit.Maximum)
+			}
+		}
+	}
+
+	if want := int(h.NumberOfSamples) * int(h.ActiveChannels); len(acq.Data) != want {
+		fail("data has %d samples, expected %d samples for %d active channels", len(acq.Data), want, h.ActiveChannels)
+	}
+	if want := int(h.NumberOfSamples) * int(h.TrajectoryDimensions); len(acq.Traj) != want {
+		fail("trajectory has %d values, expected %d for %d dimensions", len(acq.Traj), want, h.TrajectoryDimensions)
+	}
+
+	if sys := head.AcquisitionSystemInformation; sys != nil && sys.ReceiverChannels != nil &&
+		*sys.ReceiverChannels != h.AvailableChannels {
+		fail("%d channels available, but the header lists %d receiver channels", h.AvailableChannels, *sys.ReceiverChannels)
+	}
+
+	return errs
+}
+
+// CheckConsistency reads the XML header and every acquisition and reports
+// the acquisitions that CheckAcquisition finds fault with.
+func (d *Dataset) CheckConsistency() ([]ConsistencyError, error) {
+	head, err := d.ReadHeader()
+	if err != nil {
+		return nil, err
+	}
+
+	var errs []ConsistencyError
+	err = d.EachAcquisition(func(i int, acq *Acquisition) error {
+		errs = append(errs, CheckAcquisition(head, i, acq)...)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return errs, nil
+}
@@ -0,0 +1,75 @@
+package ismrmrd
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func consistentAcquisition() *Acquisition {
+	acq := &Acquisition{}
+	acq.Head.NumberOfSamples = 4
+	acq.Head.AvailableChannels = receiverChannels
+	acq.Head.ActiveChannels = 1
+	acq.Head.TrajectoryDimensions = 2
+	acq.Head.Idx.KSpaceEncodeStep1 = 64
+	acq.Head.Idx.Slice = 4
+	acq.Data = make([]complex64, 4)
+	acq.Traj = make([]float32, 8)
+	return acq
+}
+
+func TestCheckAcquisition(t *testing.T) {
+	if errs := CheckAcquisition(testHeader, 0, consistentAcquisition()); errs != nil {
+		t.Fatalf("unexpected errors %v", errs)
+	}
+
+	acq := consistentAcquisition()
+	acq.Head.Idx.Slice = 5
+	acq.Head.AvailableChannels = 2
+	acq.Data = acq.Data[:3]
+	acq.Traj = nil
+
+	errs := CheckAcquisition(testHeader, 7, acq)
+	want := []string{"slice counter 5", "data has 3 samples", "trajectory has 0 values", "2 channels available"}
+	if len(errs) != len(want) {
+		t.Fatalf("expected %d errors, found %v", len(want), errs)
+	}
+	for i, w := range want {
+		if errs[i].Acquisition != 7 || !strings.Contains(errs[i].Message, w) {
+			t.Fatalf("error %d is %v, expected it to mention %q", i, errs[i], w)
+		}
+	}
+
+	acq = consistentAcquisition()
+	acq.Head.EncodingSpaceRef = 1
+	if errs := CheckAcquisition(testHeader, 0, acq); len(errs) != 1 {
+		t.Fatalf("expected a single encoding space error, found %v", errs)
+	}
+}
+
+func TestCheckConsistency(t *testing.T) {
+	dset, err := Create(filename, groupname)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(filename)
+	defer dset.Close()
+
+	if err := dset.WriteHeader(testHeader); err != nil {
+		t.Fatal(err)
+	}
+	bad := consistentAcquisition()
+	bad.Head.EncodingSpaceRef = 3
+	if err := dset.AppendAcquisitions([]Acquisition{*consistentAcquisition(), *bad}); err != nil {
+		t.Fatal(err)
+	}
+
+	errs, err := dset.CheckConsistency()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(errs) != 1 || errs[0].Acquisition != 1 {
+		t.Fatalf("expected a single error for acquisition 1, found %v", errs)
+	}
+}
@@ -1,8 +1,10 @@
 package ismrmrd
 
 import (
+	"errors"
 	"fmt"
 	"strings"
+	"unsafe"
 
 	"github.com/sbinet/go-hdf5"
 )
@@ -11,52 +13,147 @@ type Dataset struct {
 	file      *hdf5.File
 	groupname string
 	group     *hdf5.Group
+	readOnly  bool
+	storage   StorageOptions
+	ownsFile  bool
 }
 
-func Open(filename, groupname string) (dset *Dataset, err error) {
-	var file *hdf5.File
-	file, err = hdf5.OpenFile(filename, hdf5.F_ACC_RDWR)
+// OpenFlag controls how OpenDataset and OpenFile open a file. Exactly one
+// of ReadOnly or ReadWrite must be given; the remaining flags may be or'ed
+// in with ReadWrite.
+type OpenFlag int
+
+const (
+	// ReadOnly opens an existing file and group for reading only.
+	ReadOnly OpenFlag = 1 << iota
+	// ReadWrite opens the file for reading and writing.
+	ReadWrite
+	// CreateIfMissing creates the file and group if they do not exist.
+	CreateIfMissing
+	// FailIfExists, together with CreateIfMissing, fails if the file
+	// already exists.
+	FailIfExists
+	// Truncate discards the contents of an existing file.
+	Truncate
+)
+
+// StorageOptions controls the HDF5 layout of the acquisition, image and
+// array datasets. The options take effect when a dataset is first created;
+// appending to an existing dataset keeps its original layout.
+type StorageOptions struct {
+	// ChunkSize is the number of elements (acquisitions, images or arrays)
+	// per chunk along the append dimension. Zero means one.
+	ChunkSize uint
+	// Deflate is the gzip compression level, from 1 to 9. Zero disables
+	// compression.
+	Deflate int
+	// Shuffle enables the byte-shuffle filter ahead of compression.
+	Shuffle bool
+}
+
+func (opts StorageOptions) validate() error {
+	if opts.Deflate < 0 || opts.Deflate > 9 {
+		return fmt.Errorf("invalid deflate level %d", opts.Deflate)
+	}
+	return nil
+}
+
+// ErrReadOnly is returned when writing to a dataset opened with ReadOnly.
+var ErrReadOnly = errors.New("dataset is open read-only")
+
+// Open opens the group groupname of an existing file for reading and
+// writing.
+func Open(filename, groupname string) (*Dataset, error) {
+	return OpenDataset(filename, groupname, ReadWrite)
+}
+
+// Create creates the file filename, truncating any existing file, and a new
+// group groupname in it.
+func Create(filename, groupname string) (*Dataset, error) {
+	return OpenDataset(filename, groupname, ReadWrite|CreateIfMissing|Truncate)
+}
+
+// OpenDataset opens the group groupname of filename as specified by flag.
+// Use OpenFile to work with several groups of the same file.
+func OpenDataset(filename, groupname string, flag OpenFlag) (*Dataset, error) {
+	f, err := OpenFile(filename, flag)
 	if err != nil {
-		return
+		return nil, err
 	}
 
-	var group *hdf5.Group
-	group, err = file.OpenGroup(groupname)
+	dset, err := f.openGroup(groupname, flag&CreateIfMissing != 0)
 	if err != nil {
-		return
+		f.Close()
+		return nil, err
 	}
+	dset.ownsFile = true
 
-	dset = &Dataset{file, groupname, group}
-	return
+	return dset, nil
 }
 
-func Create(filename, groupname string) (dset *Dataset, err error) {
-	var file *hdf5.File
-	file, err = hdf5.CreateFile(filename, hdf5.F_ACC_TRUNC)
-	if err != nil {
-		return
+// CreateWithOptions is like Create, but sets the storage options used for
+// the acquisition, image and array datasets created in the group.
+func CreateWithOptions(filename, groupname string, opts StorageOptions) (*Dataset, error) {
+	if err := opts.validate(); err != nil {
+		return nil, err
 	}
 
-	var group *hdf5.Group
-	group, err = file.CreateGroup(groupname)
+	dset, err := Create(filename, groupname)
 	if err != nil {
-		return
+		return nil, err
 	}
+	dset.storage = opts
 
-	dset = &Dataset{file, groupname, group}
-	return
+	return dset, nil
+}
+
+// IsReadOnly reports whether the dataset was opened read-only.
+func (d *Dataset) IsReadOnly() bool {
+	return d.readOnly
+}
+
+// SetStorageOptions sets the storage options used for datasets created by
+// subsequent appends.
+func (d *Dataset) SetStorageOptions(opts StorageOptions) error {
+	if err := opts.validate(); err != nil {
+		return err
+	}
+	d.storage = opts
+	return nil
+}
+
+// storageOptions returns the per-append override, if any, or the dataset's
+// storage options.
+func (d *Dataset) storageOptions(opts []StorageOptions) (StorageOptions, error) {
+	if len(opts) == 0 {
+		return d.storage, nil
+	}
+	if len(opts) > 1 {
+		return StorageOptions{}, fmt.Errorf("at most one StorageOptions may be given")
+	}
+	return opts[0], opts[0].validate()
+}
+
+func (d *Dataset) checkWritable() error {
+	if d.readOnly {
+		return ErrReadOnly
+	}
+	return nil
 }
 
 func (d *Dataset) Close() error {
 	if err := d.group.Close(); err != nil {
 		return err
 	}
-	if err := d.file.Close(); err != nil {
-		return err
+	if d.ownsFile {
+		if err := d.file.Close(); err != nil {
+			return err
+		}
 	}
 	return nil
 }
 
+// ReadXMLHeader returns the raw XML header stored in <group>/xml.
 func (d *Dataset) ReadXMLHeader() (xml string, err error) {
 	var dataset *hdf5.Dataset
 	dataset, err = d.file.OpenDataset(d.makePath("xml"))
@@ -65,31 +162,40 @@ func (d *Dataset) ReadXMLHeader() (xml string, err error) {
 	}
 	defer dataset.Close()
 
-	// TODO: delete this block... just checking that datatypes
-	// are automatically loaded correctly
-	var dtype *hdf5.Datatype
-	dtype, err = dataset.Datatype()
-	if err != nil {
-		return
-	}
-	defer dtype.Close()
-	if !dtype.Equal(hdf5.T_GO_STRING) {
-		err = fmt.Errorf("invalid datatype")
-		return
-	}
-
 	wrapper := make([]string, 1)
 	if err = dataset.Read(&wrapper); err != nil {
 		return
 	}
-	fmt.Println("read: ", wrapper[0])
 
 	xml = wrapper[0]
 
 	return
 }
 
+// ReadHeader reads and parses the XML header stored in <group>/xml.
+func (d *Dataset) ReadHeader() (*IsmrmrdHeader, error) {
+	xml, err := d.ReadXMLHeader()
+	if err != nil {
+		return nil, err
+	}
+	return Deserialize([]byte(xml))
+}
+
+// WriteHeader serializes head and stores it in <group>/xml.
+func (d *Dataset) WriteHeader(head *IsmrmrdHeader) error {
+	b, err := Serialize(head)
+	if err != nil {
+		return err
+	}
+	return d.WriteXMLHeader(string(b))
+}
+
+// WriteXMLHeader stores the raw XML header in <group>/xml.
 func (d *Dataset) WriteXMLHeader(header string) error {
+	if err := d.checkWritable(); err != nil {
+		return err
+	}
+
 	dataspace, err := hdf5.CreateSimpleDataspace([]uint{1}, nil)
 	if err != nil {
 		return err
@@ -98,7 +204,13 @@ func (d *Dataset) WriteXMLHeader(header string) error {
 
 	datatype := hdf5.T_GO_STRING
 
-	dataset, err := d.file.CreateDataset(d.makePath("xml"), datatype, dataspace)
+	// An existing header is overwritten in place.
+	var dataset *hdf5.Dataset
+	if path := d.makePath("xml"); d.file.LinkExists(path) {
+		dataset, err = d.file.OpenDataset(path)
+	} else {
+		dataset, err = d.file.CreateDataset(path, datatype, dataspace)
+	}
 	if err != nil {
 		return err
 	}
@@ -134,25 +246,366 @@ func (d *Dataset) NumberOfAcquisitions() int {
 	return int(d.numberOfElements(d.makePath("data")))
 }
 
-// func (d *Dataset) ReadAcquisition(acqNum int) (*Acquisition, error) {
+// ReadAcquisition reads the acquisition at index acqNum from the
+// <group>/data dataset.
+func (d *Dataset) ReadAcquisition(acqNum int) (*Acquisition, error) {
+	acqs, err := d.ReadAcquisitions(acqNum, 1)
+	if err != nil {
+		return nil, err
+	}
+	return &acqs[0], nil
+}
+
+// ReadAcquisitions reads count consecutive acquisitions starting at index
+// start from the <group>/data dataset, using a single hyperslab selection.
+func (d *Dataset) ReadAcquisitions(start, count int) ([]Acquisition, error) {
+	if start < 0 {
+		return nil, fmt.Errorf("invalid acquisition number %d", start)
+	}
+	if count < 1 {
+		return nil, fmt.Errorf("invalid acquisition count %d", count)
+	}
+
+	dtype, err := acquisitionType()
+	if err != nil {
+		return nil, err
+	}
+	defer dtype.Close()
+
+	buf := make([]hdf5Acquisition, count)
+	acqs := make([]Acquisition, count)
+	err = d.readElements(d.makePath("data"), dtype, nil, uint(start), uint(count), unsafe.Pointer(&buf[0]), func() {
+		for i := range buf {
+			acqs[i].Head = buf[i].Head
+			acqs[i].Traj = buf[i].Traj.floats()
+			acqs[i].Data = buf[i].Data.complexes()
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return acqs, nil
+}
+
+// acquisitionBatchSize is the number of acquisitions read at a time when
+// walking a whole dataset.
+const acquisitionBatchSize = 1024
+
+// forEachBatch calls fn with consecutive ranges, of at most
+// acquisitionBatchSize elements, covering the first n elements of a
+// dataset. It stops at the first error fn returns.
+func (d *Dataset) forEachBatch(n int, fn func(start, count int) error) error {
+	for start := 0; start < n; start += acquisitionBatchSize {
+		count := acquisitionBatchSize
+		if start+count > n {
+			count = n - start
+		}
+		if err := fn(start, count); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// EachAcquisition calls fn with every acquisition in the <group>/data
+// dataset in order, reading them in batches. It stops at the first error
+// fn returns.
+func (d *Dataset) EachAcquisition(fn func(i int, acq *Acquisition) error) error {
+	return d.forEachBatch(d.NumberOfAcquisitions(), func(start, count int) error {
+		acqs, err := d.ReadAcquisitions(start, count)
+		if err != nil {
+			return err
+		}
+		for i := range acqs {
+			if err := fn(start+i, &acqs[i]); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// AppendAcquisition appends acq to the <group>/data dataset, creating the
+// dataset if necessary. opts, if given, overrides the dataset's storage
+// options.
+func (d *Dataset) AppendAcquisition(acq *Acquisition, opts ...StorageOptions) error {
+	return d.AppendAcquisitions([]Acquisition{*acq}, opts...)
+}
+
+// AppendAcquisitions appends acqs to the <group>/data dataset, extending it
+// only once. opts, if given, overrides the dataset's storage options.
+func (d *Dataset) AppendAcquisitions(acqs []Acquisition, opts ...StorageOptions) error {
+	if err := d.checkWritable(); err != nil {
+		return err
+	}
+	storage, err := d.storageOptions(opts)
+	if err != nil {
+		return err
+	}
+
+	if len(acqs) == 0 {
+		return nil
+	}
+
+	dtype, err := acquisitionType()
+	if err != nil {
+		return err
+	}
+	defer dtype.Close()
+
+	buf := make([]hdf5Acquisition, len(acqs))
+	defer func() {
+		for i := range buf {
+			freeVL(buf[i].Traj)
+			freeVL(buf[i].Data)
+		}
+	}()
+	for i := range acqs {
+		buf[i].Head = acqs[i].Head
+		buf[i].Traj = newFloatsVL(acqs[i].Traj)
+		buf[i].Data = newComplexVL(acqs[i].Data)
+	}
+
+	return d.appendElements(d.makePath("data"), dtype, nil, uint(len(buf)), unsafe.Pointer(&buf[0]), storage)
+}
+
+func (d *Dataset) NumberOfWaveforms() int {
+	return int(d.numberOfElements(d.makePath("waveforms")))
+}
+
+// ReadWaveform reads the waveform at index wavNum from the
+// <group>/waveforms dataset.
+func (d *Dataset) ReadWaveform(wavNum int) (*Waveform, error) {
+	if wavNum < 0 {
+		return nil, fmt.Errorf("invalid waveform number %d", wavNum)
+	}
+
+	dtype, err := waveformType()
+	if err != nil {
+		return nil, err
+	}
+	defer dtype.Close()
+
+	buf := make([]hdf5Waveform, 1)
+	wav := &Waveform{}
+	err = d.readElements(d.makePath("waveforms"), dtype, nil, uint(wavNum), 1, unsafe.Pointer(&buf[0]), func() {
+		wav.Head = buf[0].Head
+		wav.Data = buf[0].Data.uint32s()
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return wav, nil
+}
+
+// AppendWaveform appends wav to the <group>/waveforms dataset, creating the
+// dataset if necessary. opts, if given, overrides the dataset's storage
+// options.
+func (d *Dataset) AppendWaveform(wav *Waveform, opts ...StorageOptions) error {
+	if err := d.checkWritable(); err != nil {
+		return err
+	}
+	storage, err := d.storageOptions(opts)
+	if err != nil {
+		return err
+	}
+
+	dtype, err := waveformType()
+	if err != nil {
+		return err
+	}
+	defer dtype.Close()
 
-// }
+	buf := []hdf5Waveform{{Head: wav.Head, Data: newUint32VL(wav.Data)}}
+	defer freeVL(buf[0].Data)
 
-// func (d *Dataset) AppendAcquisition(acq *Acquisition) error {
+	return d.appendElements(d.makePath("waveforms"), dtype, nil, 1, unsafe.Pointer(&buf[0]), storage)
+}
 
-// }
+// ImageSeriesPath returns the path, relative to the dataset, under which the
+// ISMRMRD tools store the image series img belongs to: "image_<series>".
+func ImageSeriesPath(img *Image) string {
+	return fmt.Sprintf("image_%d", img.Head.ImageSeriesIndex)
+}
 
 func (d *Dataset) NumberOfImages(imgPath string) int {
 	return int(d.numberOfElements(d.makePath(imgPath, "header")))
 }
 
-// func (d *Dataset) ReadImage(imgPath string, imgNum int) (*Image, error) {
+// ReadImage reads the image at index imgNum from the image series stored
+// under <group>/<imgPath>.
+func (d *Dataset) ReadImage(imgPath string, imgNum int) (*Image, error) {
+	if imgNum < 0 {
+		return nil, fmt.Errorf("invalid image number %d", imgNum)
+	}
+	index := uint(imgNum)
+
+	htype, err := imageHeaderType()
+	if err != nil {
+		return nil, err
+	}
+	defer htype.Close()
+
+	img := &Image{}
+	err = d.readElements(d.makePath(imgPath, "header"), htype, nil, index, 1, unsafe.Pointer(&img.Head), func() {})
+	if err != nil {
+		return nil, err
+	}
+
+	attrs := make([]unsafe.Pointer, 1)
+	err = d.readElements(d.makePath(imgPath, "attributes"), hdf5.T_GO_STRING, nil, index, 1, unsafe.Pointer(&attrs[0]), func() {
+		img.Attributes = goString(attrs[0])
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	dtype, err := elementType(img.Head.DataType)
+	if err != nil {
+		return nil, err
+	}
+	defer dtype.Close()
+
+	if img.NumberOfElements() == 0 {
+		return nil, fmt.Errorf("image %d in %s has no pixels", imgNum, imgPath)
+	}
+	img.Data, err = makeData(img.Head.DataType, img.NumberOfElements())
+	if err != nil {
+		return nil, err
+	}
+	err = d.readElements(d.makePath(imgPath, "data"), dtype, imageDims(&img.Head), index, 1, dataPointer(img.Data), func() {})
+	if err != nil {
+		return nil, err
+	}
+
+	return img, nil
+}
+
+// AppendImage appends img to the image series stored under
+// <group>/<imgPath>, creating the series if necessary. Every image in a
+// series must have the same data type and dimensions. opts, if given,
+// overrides the dataset's storage options.
+func (d *Dataset) AppendImage(imgPath string, img *Image, opts ...StorageOptions) error {
+	if err := d.checkWritable(); err != nil {
+		return err
+	}
+	storage, err := d.storageOptions(opts)
+	if err != nil {
+		return err
+	}
+
+	dataType, n, err := dataTypeOf(img.Data)
+	if err != nil {
+		return err
+	}
+	if dataType != img.Head.DataType {
+		return fmt.Errorf("image data is %T but header data type is %d", img.Data, img.Head.DataType)
+	}
+	if n == 0 || n != img.NumberOfElements() {
+		return fmt.Errorf("image has %d pixels, header describes %d", n, img.NumberOfElements())
+	}
+
+	if err := d.createGroup(d.makePath(imgPath)); err != nil {
+		return err
+	}
+
+	htype, err := imageHeaderType()
+	if err != nil {
+		return err
+	}
+	defer htype.Close()
+
+	head := img.Head
+	head.AttributeStringLen = uint32(len(img.Attributes))
+	if err := d.appendElements(d.makePath(imgPath, "header"), htype, nil, 1, unsafe.Pointer(&head), storage); err != nil {
+		return err
+	}
+
+	attrs := []unsafe.Pointer{newCString(img.Attributes)}
+	defer freeCString(attrs[0])
+	if err := d.appendElements(d.makePath(imgPath, "attributes"), hdf5.T_GO_STRING, nil, 1, unsafe.Pointer(&attrs[0]), storage); err != nil {
+		return err
+	}
+
+	dtype, err := elementType(dataType)
+	if err != nil {
+		return err
+	}
+	defer dtype.Close()
+
+	return d.appendElements(d.makePath(imgPath, "data"), dtype, imageDims(&img.Head), 1, dataPointer(img.Data), storage)
+}
+
+// imageDims returns the dimensions of one image in the <imgPath>/data
+// dataset, slowest varying first.
+func imageDims(h *ImageHeader) []uint {
+	return []uint{uint(h.Channels), uint(h.MatrixSize[2]), uint(h.MatrixSize[1]), uint(h.MatrixSize[0])}
+}
+
+// Entry names an image series or array variable within a Dataset group and
+// gives its number of elements.
+type Entry struct {
+	Name  string
+	Count int
+}
+
+// Contents describes what a Dataset group holds.
+type Contents struct {
+	HasXMLHeader         bool
+	HasAcquisitions      bool
+	NumberOfAcquisitions int
+	HasWaveforms         bool
+	NumberOfWaveforms    int
+	Images               []Entry
+	Arrays               []Entry
+}
 
-// }
+// Contents walks the dataset group and lists its XML header, acquisitions,
+// waveforms, image series and array variables. Any subgroup holding a
+// header dataset is treated as an image series, and any other dataset as
+// an array variable.
+func (d *Dataset) Contents() (*Contents, error) {
+	n, err := d.group.NumObjects()
+	if err != nil {
+		return nil, err
+	}
 
-// func (d *Dataset) AppendImage(imgPath string, img *Image) error {
+	c := &Contents{}
+	for i := uint(0); i < n; i++ {
+		name, err := d.group.ObjectNameByIndex(i)
+		if err != nil {
+			return nil, err
+		}
+		otype, err := d.group.ObjectTypeByIndex(i)
+		if err != nil {
+			return nil, err
+		}
+
+		switch otype {
+		case hdf5.H5G_GROUP:
+			if d.file.LinkExists(d.makePath(name, "header")) {
+				c.Images = append(c.Images, Entry{name, d.NumberOfImages(name)})
+			}
+		case hdf5.H5G_DATASET:
+			switch name {
+			case "xml":
+				c.HasXMLHeader = true
+			case "data":
+				c.HasAcquisitions = true
+				c.NumberOfAcquisitions = d.NumberOfAcquisitions()
+			case "waveforms":
+				c.HasWaveforms = true
+				c.NumberOfWaveforms = d.NumberOfWaveforms()
+			default:
+				c.Arrays = append(c.Arrays, Entry{name, d.NumberOfArrays(name)})
+			}
+		}
+	}
 
-// }
+	return c, nil
+}
 
 func (d *Dataset) makePath(components ...string) string {
 	return strings.Join(append([]string{d.groupname}, components...), "/")
@@ -162,10 +615,283 @@ func (d *Dataset) NumberOfArrays(arrPath string) int {
 	return int(d.numberOfElements(d.makePath(arrPath)))
 }
 
-// func (d *Dataset) ReadArray(arrPath string, arrNum int) (*Array, error) {
+// ReadArray reads the array at index arrNum from the <group>/<arrPath>
+// dataset. The data type and dimensions are taken from the dataset.
+func (d *Dataset) ReadArray(arrPath string, arrNum int) (*NDArray, error) {
+	if arrNum < 0 {
+		return nil, fmt.Errorf("invalid array number %d", arrNum)
+	}
+
+	path := d.makePath(arrPath)
+	dataType, elem, err := d.arrayProperties(path)
+	if err != nil {
+		return nil, err
+	}
+
+	dims := make([]uint, len(elem))
+	for i, n := range elem {
+		dims[len(elem)-1-i] = n
+	}
+	arr, err := NewNDArray(dataType, dims...)
+	if err != nil {
+		return nil, err
+	}
+	if arr.NumberOfElements() == 0 {
+		return nil, fmt.Errorf("array %d in %s has no elements", arrNum, arrPath)
+	}
+
+	dtype, err := elementType(dataType)
+	if err != nil {
+		return nil, err
+	}
+	defer dtype.Close()
+
+	err = d.readElements(path, dtype, elem, uint(arrNum), 1, dataPointer(arr.Data), func() {})
+	if err != nil {
+		return nil, err
+	}
+
+	return arr, nil
+}
+
+// AppendArray appends arr to the <group>/<arrPath> dataset, creating the
+// dataset if necessary. Every array stored under one path must have the same
+// data type and dimensions. opts, if given, overrides the dataset's storage
+// options.
+func (d *Dataset) AppendArray(arrPath string, arr *NDArray, opts ...StorageOptions) error {
+	if err := d.checkWritable(); err != nil {
+		return err
+	}
+	storage, err := d.storageOptions(opts)
+	if err != nil {
+		return err
+	}
+
+	dataType, n, err := dataTypeOf(arr.Data)
+	if err != nil {
+		return err
+	}
+	if dataType != arr.DataType {
+		return fmt.Errorf("array data is %T but data type is %d", arr.Data, arr.DataType)
+	}
+	if len(arr.Dims) == 0 || len(arr.Dims) > ISMRMRD_NDARRAY_MAXDIM {
+		return fmt.Errorf("invalid number of dimensions %d", len(arr.Dims))
+	}
+	if n == 0 || n != arr.NumberOfElements() {
+		return fmt.Errorf("array has %d elements, dimensions describe %d", n, arr.NumberOfElements())
+	}
+
+	dtype, err := elementType(dataType)
+	if err != nil {
+		return err
+	}
+	defer dtype.Close()
+
+	elem := make([]uint, len(arr.Dims))
+	for i, n := range arr.Dims {
+		elem[len(arr.Dims)-1-i] = n
+	}
+
+	return d.appendElements(d.makePath(arrPath), dtype, elem, 1, dataPointer(arr.Data), storage)
+}
+
+// arrayProperties returns the ISMRMRD data type and per-element dimensions,
+// slowest varying first, of the array dataset at path.
+func (d *Dataset) arrayProperties(path string) (uint16, []uint, error) {
+	dataset, err := d.file.OpenDataset(path)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer dataset.Close()
+
+	dataspace := dataset.Space()
+	defer dataspace.Close()
+	dims, _, err := dataspace.SimpleExtentDims()
+	if err != nil {
+		return 0, nil, err
+	}
+	if len(dims) < 2 {
+		return 0, nil, fmt.Errorf("%s is not an array dataset", path)
+	}
+
+	ftype, err := dataset.Datatype()
+	if err != nil {
+		return 0, nil, err
+	}
+	defer ftype.Close()
+
+	for dataType := uint16(ISMRMRD_USHORT); dataType <= ISMRMRD_CXDOUBLE; dataType++ {
+		dtype, err := elementType(dataType)
+		if err != nil {
+			return 0, nil, err
+		}
+		equal := ftype.Equal(dtype)
+		dtype.Close()
+		if equal {
+			return dataType, dims[1:], nil
+		}
+	}
+
+	return 0, nil, fmt.Errorf("%s has an unsupported datatype", path)
+}
+
+// readElements reads count elements starting at start from the dataset at
+// path into buf. Each element has the trailing dimensions elem, which may be
+// nil for scalar elements. decode is called before any variable-length
+// memory HDF5 allocated in buf is released.
+func (d *Dataset) readElements(path string, dtype *hdf5.Datatype, elem []uint, start, count uint, buf unsafe.Pointer, decode func()) error {
+	dataset, err := d.file.OpenDataset(path)
+	if err != nil {
+		return err
+	}
+	defer dataset.Close()
+
+	filespace := dataset.Space()
+	defer filespace.Close()
+
+	dims, _, err := filespace.SimpleExtentDims()
+	if err != nil {
+		return err
+	}
+	if err := checkElementDims(path, dims, elem); err != nil {
+		return err
+	}
+	if start+count > dims[0] {
+		return fmt.Errorf("index %d out of range for %s (%d elements)", start+count-1, path, dims[0])
+	}
+
+	offset, extent := selection(start, count, elem)
+	if err := filespace.SelectHyperslab(offset, nil, extent, nil); err != nil {
+		return err
+	}
+
+	memspace, err := hdf5.CreateSimpleDataspace(extent, nil)
+	if err != nil {
+		return err
+	}
+	defer memspace.Close()
+
+	if err := readWithType(dataset, dtype, memspace, filespace, buf); err != nil {
+		return err
+	}
+	decode()
+	reclaim(dtype, memspace, buf)
+
+	return nil
+}
+
+// appendElements writes count elements from buf to the end of the dataset
+// at path, creating an extendible dataset laid out according to opts if none
+// exists yet. Each element has the trailing dimensions elem, which may be nil
+// for scalar elements.
+func (d *Dataset) appendElements(path string, dtype *hdf5.Datatype, elem []uint, count uint, buf unsafe.Pointer, opts StorageOptions) error {
+	dataset, err := d.openOrCreateDataset(path, dtype, elem, opts)
+	if err != nil {
+		return err
+	}
+	defer dataset.Close()
+
+	filespace := dataset.Space()
+	dims, _, err := filespace.SimpleExtentDims()
+	filespace.Close()
+	if err != nil {
+		return err
+	}
+	if err := checkElementDims(path, dims, elem); err != nil {
+		return err
+	}
+
+	dims[0] += count
+	if err := dataset.Resize(dims); err != nil {
+		return err
+	}
+
+	filespace = dataset.Space()
+	defer filespace.Close()
+	offset, extent := selection(dims[0]-count, count, elem)
+	if err := filespace.SelectHyperslab(offset, nil, extent, nil); err != nil {
+		return err
+	}
+
+	memspace, err := hdf5.CreateSimpleDataspace(extent, nil)
+	if err != nil {
+		return err
+	}
+	defer memspace.Close()
+
+	return writeWithType(dataset, dtype, memspace, filespace, buf)
+}
+
+func (d *Dataset) openOrCreateDataset(path string, dtype *hdf5.Datatype, elem []uint, opts StorageOptions) (*hdf5.Dataset, error) {
+	if d.file.LinkExists(path) {
+		return d.file.OpenDataset(path)
+	}
+
+	dims := append([]uint{0}, elem...)
+	maxdims := append([]uint{hdf5.S_UNLIMITED}, elem...)
+	dataspace, err := hdf5.CreateSimpleDataspace(dims, maxdims)
+	if err != nil {
+		return nil, err
+	}
+	defer dataspace.Close()
+
+	// Extendible datasets must be chunked.
+	dcpl, err := hdf5.NewPropList(hdf5.P_DATASET_CREATE)
+	if err != nil {
+		return nil, err
+	}
+	defer dcpl.Close()
+	chunk := opts.ChunkSize
+	if chunk == 0 {
+		chunk = 1
+	}
+	if err := dcpl.SetChunk(append([]uint{chunk}, elem...)); err != nil {
+		return nil, err
+	}
+	// The shuffle filter must precede deflate in the pipeline.
+	if opts.Shuffle {
+		if err := setShuffle(dcpl); err != nil {
+			return nil, err
+		}
+	}
+	if opts.Deflate > 0 {
+		if err := dcpl.SetDeflate(opts.Deflate); err != nil {
+			return nil, err
+		}
+	}
+
+	return d.file.CreateDatasetWith(path, dtype, dataspace, dcpl)
+}
 
-// }
+// createGroup creates the group at path unless it already exists.
+func (d *Dataset) createGroup(path string) error {
+	if d.file.LinkExists(path) {
+		return nil
+	}
+	group, err := d.file.CreateGroup(path)
+	if err != nil {
+		return err
+	}
+	return group.Close()
+}
 
-// func (d *Dataset) AppendArray(arrPath string, arr *Array) error {
+func checkElementDims(path string, dims, elem []uint) error {
+	if len(dims) != len(elem)+1 {
+		return fmt.Errorf("%s has %d dimensions, expected %d", path, len(dims), len(elem)+1)
+	}
+	for i, n := range elem {
+		if dims[i+1] != n {
+			return fmt.Errorf("%s has dimensions %v, incompatible with element dimensions %v", path, dims[1:], elem)
+		}
+	}
+	return nil
+}
 
-// }
+// selection returns the hyperslab offset and extent covering count
+// elements of dimensions elem, starting at element start.
+func selection(start, count uint, elem []uint) (offset, extent []uint) {
+	offset = make([]uint, len(elem)+1)
+	offset[0] = start
+	extent = append([]uint{count}, elem...)
+	return offset, extent
+}
@@ -1,8 +1,10 @@
 package ismrmrd
 
 import (
+	"fmt"
 	"github.com/sbinet/go-hdf5"
 	"os"
+	"reflect"
 	"testing"
 )
 
@@ -42,6 +44,68 @@ func TestOpenDataset(t *testing.T) {
 	}
 }
 
+func TestOpenDatasetReadOnly(t *testing.T) {
+	dset, err := Create(filename, groupname)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(filename)
+	if err := dset.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	dset, err = OpenDataset(filename, groupname, ReadOnly)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dset.Close()
+
+	if !dset.IsReadOnly() {
+		t.Fatal("dataset should be read-only")
+	}
+	if err := dset.WriteXMLHeader("header"); err != ErrReadOnly {
+		t.Fatalf("expected ErrReadOnly, got %v", err)
+	}
+	if err := dset.AppendAcquisition(&Acquisition{}); err != ErrReadOnly {
+		t.Fatalf("expected ErrReadOnly, got %v", err)
+	}
+
+	if _, err := OpenDataset(filename, "missing", ReadOnly); err == nil {
+		t.Fatal("expected error opening a missing group read-only")
+	}
+}
+
+func TestOpenDatasetFlags(t *testing.T) {
+	defer os.Remove(filename)
+
+	if _, err := OpenDataset(filename, groupname, ReadWrite); err == nil {
+		t.Fatal("expected error opening a missing file without CreateIfMissing")
+	}
+
+	dset, err := OpenDataset(filename, groupname, ReadWrite|CreateIfMissing)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := dset.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := OpenDataset(filename, groupname, ReadWrite|CreateIfMissing|FailIfExists); err == nil {
+		t.Fatal("expected error creating an existing file with FailIfExists")
+	}
+	if _, err := OpenDataset(filename, groupname, ReadOnly|ReadWrite); err == nil {
+		t.Fatal("expected error combining ReadOnly and ReadWrite")
+	}
+
+	dset, err = OpenDataset(filename, "noise", ReadWrite|CreateIfMissing)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := dset.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
 func TestWriteXMLHeader(t *testing.T) {
 	hdf5.DisplayErrors(true)
 
@@ -86,3 +150,462 @@ func TestReadXMLHeader(t *testing.T) {
 		t.Fatalf("XML header does not match what was written (%s)", xml)
 	}
 }
+
+func TestAppendAcquisition(t *testing.T) {
+	dset, err := Create(filename, groupname)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		dset.Close()
+		os.Remove(filename)
+	}()
+
+	acq := &Acquisition{}
+	acq.Head.NumberOfSamples = 4
+	acq.Head.ActiveChannels = 1
+	acq.Head.AvailableChannels = 1
+	acq.Data = make([]complex64, 4)
+
+	for i := 0; i < 3; i++ {
+		acq.Head.ScanCounter = uint32(i)
+		if err := dset.AppendAcquisition(acq); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if n := dset.NumberOfAcquisitions(); n != 3 {
+		t.Fatalf("expected 3 acquisitions, found %d", n)
+	}
+}
+
+func TestReadAcquisition(t *testing.T) {
+	dset, err := Create(filename, groupname)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		dset.Close()
+		os.Remove(filename)
+	}()
+
+	acq := &Acquisition{}
+	acq.Head.Version = ISMRMRD_VERSION_MAJOR
+	acq.Head.Flags = 1 << 6
+	acq.Head.ScanCounter = 42
+	acq.Head.NumberOfSamples = 3
+	acq.Head.ActiveChannels = 1
+	acq.Head.AvailableChannels = 1
+	acq.Head.TrajectoryDimensions = 2
+	acq.Head.ChannelMask[0] = 1
+	acq.Head.Position = [3]float32{1, 2, 3}
+	acq.Head.Idx.KSpaceEncodeStep1 = 17
+	acq.Head.Idx.User[7] = 9
+	acq.Head.UserFloat32[0] = 0.5
+	acq.Traj = []float32{0, 1, 2, 3, 4, 5}
+	acq.Data = []complex64{complex(1, -1), complex(2, -2), complex(3, -3)}
+
+	if err := dset.AppendAcquisition(acq); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := dset.ReadAcquisition(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got.Head != acq.Head {
+		t.Fatalf("acquisition header does not match what was written (%+v)", got.Head)
+	}
+	if len(got.Traj) != len(acq.Traj) {
+		t.Fatalf("trajectory length %d, expected %d", len(got.Traj), len(acq.Traj))
+	}
+	for i := range acq.Traj {
+		if got.Traj[i] != acq.Traj[i] {
+			t.Fatalf("trajectory does not match what was written (%v)", got.Traj)
+		}
+	}
+	if len(got.Data) != len(acq.Data) {
+		t.Fatalf("data length %d, expected %d", len(got.Data), len(acq.Data))
+	}
+	for i := range acq.Data {
+		if got.Data[i] != acq.Data[i] {
+			t.Fatalf("data does not match what was written (%v)", got.Data)
+		}
+	}
+
+	if _, err := dset.ReadAcquisition(1); err == nil {
+		t.Fatal("expected error reading past the last acquisition")
+	}
+}
+
+func TestReadImage(t *testing.T) {
+	dset, err := Create(filename, groupname)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		dset.Close()
+		os.Remove(filename)
+	}()
+
+	for _, dtype := range []uint16{
+		ISMRMRD_USHORT, ISMRMRD_SHORT, ISMRMRD_UINT, ISMRMRD_INT,
+		ISMRMRD_FLOAT, ISMRMRD_DOUBLE, ISMRMRD_CXFLOAT, ISMRMRD_CXDOUBLE,
+	} {
+		img, err := NewImage(dtype, 4, 3, 2, 2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		img.Head.ImageIndex = 7
+		img.Attributes = "<ismrmrdMeta></ismrmrdMeta>"
+		fillData(img.Data)
+
+		imgPath := fmt.Sprintf("image_%d", dtype)
+		if err := dset.AppendImage(imgPath, img); err != nil {
+			t.Fatal(err)
+		}
+		if n := dset.NumberOfImages(imgPath); n != 1 {
+			t.Fatalf("expected 1 image in %s, found %d", imgPath, n)
+		}
+
+		got, err := dset.ReadImage(imgPath, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.Head.ImageIndex != img.Head.ImageIndex || got.Head.DataType != dtype {
+			t.Fatalf("image header does not match what was written (%+v)", got.Head)
+		}
+		if got.Attributes != img.Attributes {
+			t.Fatalf("image attributes do not match what was written (%s)", got.Attributes)
+		}
+		if _, n, err := dataTypeOf(got.Data); err != nil || n != img.NumberOfElements() {
+			t.Fatalf("image data has the wrong type or size (%T)", got.Data)
+		}
+		if !reflect.DeepEqual(got.Data, img.Data) {
+			t.Fatalf("image data of type %d does not match what was written (%v)", dtype, got.Data)
+		}
+	}
+}
+
+// fillData sets each element of a slice produced by makeData to a value
+// derived from its index, with distinct real and imaginary parts.
+func fillData(data interface{}) {
+	switch v := data.(type) {
+	case []uint16:
+		for i := range v {
+			v[i] = uint16(i + 1)
+		}
+	case []int16:
+		for i := range v {
+			v[i] = int16(-i)
+		}
+	case []uint32:
+		for i := range v {
+			v[i] = uint32(i + 1)
+		}
+	case []int32:
+		for i := range v {
+			v[i] = int32(-i)
+		}
+	case []float32:
+		for i := range v {
+			v[i] = float32(i) + 0.5
+		}
+	case []float64:
+		for i := range v {
+			v[i] = float64(i) + 0.25
+		}
+	case []complex64:
+		for i := range v {
+			v[i] = complex(float32(i), -float32(2*i+1))
+		}
+	case []complex128:
+		for i := range v {
+			v[i] = complex(float64(i), -float64(2*i+1))
+		}
+	}
+}
+
+func TestReadArray(t *testing.T) {
+	dset, err := Create(filename, groupname)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		dset.Close()
+		os.Remove(filename)
+	}()
+
+	arr, err := NewNDArray(ISMRMRD_CXFLOAT, 8, 4, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := arr.Data.([]complex64)
+	for i := range data {
+		data[i] = complex(float32(i), -float32(i))
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := dset.AppendArray("csm", arr); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := dset.NumberOfArrays("csm"); n != 2 {
+		t.Fatalf("expected 2 arrays, found %d", n)
+	}
+
+	got, err := dset.ReadArray("csm", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.DataType != ISMRMRD_CXFLOAT {
+		t.Fatalf("array data type %d, expected %d", got.DataType, ISMRMRD_CXFLOAT)
+	}
+	if len(got.Dims) != 3 || got.Dims[0] != 8 || got.Dims[1] != 4 || got.Dims[2] != 2 {
+		t.Fatalf("array dimensions %v do not match what was written", got.Dims)
+	}
+	for i, v := range got.Data.([]complex64) {
+		if v != data[i] {
+			t.Fatalf("array data does not match what was written (%v)", got.Data)
+		}
+	}
+
+	bad, _ := NewNDArray(ISMRMRD_CXFLOAT, 4, 4)
+	if err := dset.AppendArray("csm", bad); err == nil {
+		t.Fatal("expected error appending an array with different dimensions")
+	}
+}
+
+func TestReadAcquisitions(t *testing.T) {
+	dset, err := Create(filename, groupname)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		dset.Close()
+		os.Remove(filename)
+	}()
+
+	acqs := make([]Acquisition, 10)
+	for i := range acqs {
+		acqs[i].Head.ScanCounter = uint32(i)
+		acqs[i].Head.NumberOfSamples = uint16(i + 1)
+		acqs[i].Head.ActiveChannels = 1
+		acqs[i].Data = make([]complex64, i+1)
+		acqs[i].Data[i] = complex(float32(i), 0)
+	}
+	if err := dset.AppendAcquisitions(acqs); err != nil {
+		t.Fatal(err)
+	}
+	if n := dset.NumberOfAcquisitions(); n != len(acqs) {
+		t.Fatalf("expected %d acquisitions, found %d", len(acqs), n)
+	}
+
+	got, err := dset.ReadAcquisitions(3, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 5 {
+		t.Fatalf("expected 5 acquisitions, read %d", len(got))
+	}
+	for i, acq := range got {
+		want := &acqs[i+3]
+		if acq.Head != want.Head || len(acq.Data) != len(want.Data) ||
+			acq.Data[len(acq.Data)-1] != want.Data[len(want.Data)-1] {
+			t.Fatalf("acquisition %d does not match what was written", i+3)
+		}
+	}
+
+	if _, err := dset.ReadAcquisitions(8, 5); err == nil {
+		t.Fatal("expected error reading past the last acquisition")
+	}
+}
+
+func TestEachAcquisition(t *testing.T) {
+	dset, err := Create(filename, groupname)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		dset.Close()
+		os.Remove(filename)
+	}()
+
+	// Enough acquisitions to span more than one batch.
+	const n = acquisitionBatchSize + 3
+	for i := 0; i < n; i++ {
+		acq := &Acquisition{}
+		acq.Head.ScanCounter = uint32(i)
+		if err := dset.AppendAcquisition(acq); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	seen := 0
+	err = dset.EachAcquisition(func(i int, acq *Acquisition) error {
+		if i != seen || acq.Head.ScanCounter != uint32(i) {
+			t.Fatalf("acquisition %d has scan counter %d, expected %d", i, acq.Head.ScanCounter, seen)
+		}
+		seen++
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if seen != n {
+		t.Fatalf("visited %d acquisitions, expected %d", seen, n)
+	}
+}
+
+func TestCreateWithOptions(t *testing.T) {
+	if _, err := CreateWithOptions(filename, groupname, StorageOptions{Deflate: 10}); err == nil {
+		os.Remove(filename)
+		t.Fatal("expected error for invalid deflate level")
+	}
+
+	dset, err := CreateWithOptions(filename, groupname, StorageOptions{ChunkSize: 16, Deflate: 6, Shuffle: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		dset.Close()
+		os.Remove(filename)
+	}()
+
+	acqs := make([]Acquisition, 40)
+	for i := range acqs {
+		acqs[i].Head.ScanCounter = uint32(i)
+		acqs[i].Head.NumberOfSamples = 64
+		acqs[i].Head.ActiveChannels = 1
+		acqs[i].Data = make([]complex64, 64)
+	}
+	if err := dset.AppendAcquisitions(acqs); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := dset.ReadAcquisitions(0, len(acqs))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got[len(got)-1].Head.ScanCounter != uint32(len(acqs)-1) {
+		t.Fatal("compressed acquisitions do not match what was written")
+	}
+
+	img, err := NewImage(ISMRMRD_FLOAT, 8, 8, 1, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := dset.AppendImage("image_0", img, StorageOptions{Deflate: 1}); err != nil {
+		t.Fatal(err)
+	}
+	if err := dset.AppendImage("image_0", img, StorageOptions{Deflate: -1}); err == nil {
+		t.Fatal("expected error for invalid deflate level")
+	}
+}
+
+func TestContents(t *testing.T) {
+	dset, err := Create(filename, groupname)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		dset.Close()
+		os.Remove(filename)
+	}()
+
+	if err := dset.WriteXMLHeader("header"); err != nil {
+		t.Fatal(err)
+	}
+	acq := &Acquisition{}
+	for i := 0; i < 2; i++ {
+		if err := dset.AppendAcquisition(acq); err != nil {
+			t.Fatal(err)
+		}
+	}
+	img, _ := NewImage(ISMRMRD_USHORT, 4, 4, 1, 1)
+	for i := 0; i < 3; i++ {
+		if err := dset.AppendImage("image_1", img); err != nil {
+			t.Fatal(err)
+		}
+	}
+	arr, _ := NewNDArray(ISMRMRD_CXFLOAT, 4, 4)
+	if err := dset.AppendArray("noise_covariance", arr); err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := dset.Contents()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !c.HasXMLHeader || !c.HasAcquisitions || c.NumberOfAcquisitions != 2 {
+		t.Fatalf("unexpected contents %+v", c)
+	}
+	if len(c.Images) != 1 || c.Images[0] != (Entry{"image_1", 3}) {
+		t.Fatalf("unexpected image series %+v", c.Images)
+	}
+	if len(c.Arrays) != 1 || c.Arrays[0] != (Entry{"noise_covariance", 1}) {
+		t.Fatalf("unexpected arrays %+v", c.Arrays)
+	}
+}
+
+func TestReadWaveform(t *testing.T) {
+	dset, err := Create(filename, groupname)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		dset.Close()
+		os.Remove(filename)
+	}()
+
+	wav := &Waveform{}
+	wav.Head.Version = ISMRMRD_VERSION_MAJOR
+	wav.Head.TimeStamp = 1234
+	wav.Head.NumberOfSamples = 3
+	wav.Head.Channels = 2
+	wav.Head.SampleTimeUs = 2.5
+	wav.Head.WaveformID = 1
+	wav.Data = []uint32{1, 2, 3, 4, 5, 6}
+
+	if err := dset.AppendWaveform(wav); err != nil {
+		t.Fatal(err)
+	}
+	if n := dset.NumberOfWaveforms(); n != 1 {
+		t.Fatalf("expected 1 waveform, found %d", n)
+	}
+
+	got, err := dset.ReadWaveform(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Head != wav.Head {
+		t.Fatalf("waveform header does not match what was written (%+v)", got.Head)
+	}
+	if len(got.Data) != len(wav.Data) || got.Data[5] != wav.Data[5] {
+		t.Fatalf("waveform data does not match what was written (%v)", got.Data)
+	}
+}
+
+func TestReadHeader(t *testing.T) {
+	dset, err := Create(filename, groupname)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		dset.Close()
+		os.Remove(filename)
+	}()
+
+	if err := dset.WriteHeader(testHeader); err != nil {
+		t.Fatal(err)
+	}
+
+	head, err := dset.ReadHeader()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !equal(head, testHeader) {
+		t.Fatal("header does not match what was written")
+	}
+}
@@ -0,0 +1,114 @@
+package ismrmrd
+
+import (
+	"fmt"
+	"time"
+)
+
+// Layouts of the XSD date and time types, without and with a time zone.
+// Fractional seconds are accepted by time.Parse after the seconds field.
+var (
+	xsdDateLayouts = []string{"2006-01-02", "2006-01-02Z07:00"}
+	xsdTimeLayouts = []string{"15:04:05", "15:04:05Z07:00"}
+)
+
+// xsdUTC marks a value with an explicit UTC zone, "Z" or "+00:00", which
+// time.Parse would otherwise return in time.UTC like a value without one.
+var xsdUTC = time.FixedZone("", 0)
+
+func parseXSD(layouts []string, kind, name, value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, fmt.Errorf("%s is not set", name)
+	}
+	for i, layout := range layouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			if i > 0 && t.Location() == time.UTC {
+				t = t.In(xsdUTC)
+			}
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("%s %q is not a valid %s", name, value, kind)
+}
+
+func parseXSDDate(name, value string) (time.Time, error) {
+	return parseXSD(xsdDateLayouts, "date", name, value)
+}
+
+// parseXSDTime returns the time of day on January 1, year 0.
+func parseXSDTime(name, value string) (time.Time, error) {
+	return parseXSD(xsdTimeLayouts, "time", name, value)
+}
+
+// xsdZone returns the time zone suffix for t. Values without a zone are
+// parsed as time.UTC, so time.UTC is written without one; any other
+// location, including Local and the explicit UTC zone of a parsed value, is
+// written as its offset so that the value keeps its meaning.
+func xsdZone(t time.Time) string {
+	if t.Location() == time.UTC {
+		return ""
+	}
+	return t.Format("Z07:00")
+}
+
+func formatXSDDate(t time.Time) string {
+	return t.Format("2006-01-02") + xsdZone(t)
+}
+
+// formatXSDTime formats the time of day in t's location, with fractional
+// seconds only when present.
+func formatXSDTime(t time.Time) string {
+	return t.Format("15:04:05.999999999") + xsdZone(t)
+}
+
+// ParsePatientBirthdate parses PatientBirthdate as an XSD date.
+func (s *SubjectInformation) ParsePatientBirthdate() (time.Time, error) {
+	return parseXSDDate("patientBirthdate", s.PatientBirthdate)
+}
+
+// SetPatientBirthdate sets PatientBirthdate to the date of t.
+func (s *SubjectInformation) SetPatientBirthdate(t time.Time) {
+	s.PatientBirthdate = formatXSDDate(t)
+}
+
+// ParseStudyDate parses StudyDate as an XSD date.
+func (s *StudyInformation) ParseStudyDate() (time.Time, error) {
+	return parseXSDDate("studyDate", s.StudyDate)
+}
+
+// ParseStudyTime parses StudyTime as an XSD time. The date of the result
+// is January 1, year 0.
+func (s *StudyInformation) ParseStudyTime() (time.Time, error) {
+	return parseXSDTime("studyTime", s.StudyTime)
+}
+
+// SetStudyDate sets StudyDate to the date of t.
+func (s *StudyInformation) SetStudyDate(t time.Time) {
+	s.StudyDate = formatXSDDate(t)
+}
+
+// SetStudyTime sets StudyTime to the time of day of t.
+func (s *StudyInformation) SetStudyTime(t time.Time) {
+	s.StudyTime = formatXSDTime(t)
+}
+
+// ParseSeriesDate parses SeriesDate as an XSD date.
+func (m *MeasurementInformation) ParseSeriesDate() (time.Time, error) {
+	return parseXSDDate("seriesDate", m.SeriesDate)
+}
+
+// ParseSeriesTime parses SeriesTime as an XSD time. The date of the result
+// is January 1, year 0.
+func (m *MeasurementInformation) ParseSeriesTime() (time.Time, error) {
+	return parseXSDTime("seriesTime", m.SeriesTime)
+}
+
+// SetSeriesDate sets SeriesDate to the date of t.
+func (m *MeasurementInformation) SetSeriesDate(t time.Time) {
+	m.SeriesDate = formatXSDDate(t)
+}
+
+// SetSeriesTime sets SeriesTime to the time of day of t.
+func (m *MeasurementInformation) SetSeriesTime(t time.Time) {
+	m.SeriesTime = formatXSDTime(t)
+}
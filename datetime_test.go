@@ -0,0 +1,111 @@
+package ismrmrd
+
+import (
+	"testing"
+	"time"
+)
+
+func TestStudyDateTime(t *testing.T) {
+	s := &StudyInformation{StudyDate: studyDate, StudyTime: studyTime}
+
+	d, err := s.ParseStudyDate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.Year() != 2014 || d.Month() != time.December || d.Day() != 31 {
+		t.Fatalf("study date parsed as %v", d)
+	}
+	tm, err := s.ParseStudyTime()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tm.Hour() != 8 || tm.Minute() != 45 || tm.Second() != 0 {
+		t.Fatalf("study time parsed as %v", tm)
+	}
+
+	when := time.Date(2020, time.February, 29, 13, 7, 9, 250000000, time.UTC)
+	s.SetStudyDate(when)
+	s.SetStudyTime(when)
+	if s.StudyDate != "2020-02-29" || s.StudyTime != "13:07:09.25" {
+		t.Fatalf("study date and time formatted as %q %q", s.StudyDate, s.StudyTime)
+	}
+	if tm, err = s.ParseStudyTime(); err != nil || tm.Nanosecond() != 250000000 {
+		t.Fatalf("fractional seconds were not parsed (%v, %v)", tm, err)
+	}
+}
+
+func TestSeriesDateTime(t *testing.T) {
+	m := &MeasurementInformation{SeriesDate: "2014-12-31+01:00", SeriesTime: "08:46:00Z"}
+	if _, err := m.ParseSeriesDate(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.ParseSeriesTime(); err != nil {
+		t.Fatal(err)
+	}
+
+	m.SetSeriesDate(time.Date(1999, time.January, 2, 0, 0, 0, 0, time.UTC))
+	m.SetSeriesTime(time.Date(0, time.January, 1, 23, 59, 58, 0, time.UTC))
+	if m.SeriesDate != "1999-01-02" || m.SeriesTime != "23:59:58" {
+		t.Fatalf("series date and time formatted as %q %q", m.SeriesDate, m.SeriesTime)
+	}
+}
+
+func TestDateTimeZone(t *testing.T) {
+	m := &MeasurementInformation{SeriesDate: "2014-12-31+01:00", SeriesTime: "08:46:00-05:00"}
+	d, err := m.ParseSeriesDate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tm, err := m.ParseSeriesTime()
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.SetSeriesDate(d)
+	m.SetSeriesTime(tm)
+	if m.SeriesDate != "2014-12-31+01:00" || m.SeriesTime != "08:46:00-05:00" {
+		t.Fatalf("zones were not kept: %q %q", m.SeriesDate, m.SeriesTime)
+	}
+
+	m.SetSeriesTime(time.Date(2020, time.June, 1, 12, 0, 0, 0, time.FixedZone("", 0)))
+	if m.SeriesTime != "12:00:00Z" {
+		t.Fatalf("series time formatted as %q", m.SeriesTime)
+	}
+
+	// An explicit UTC zone is kept, while a value without one stays
+	// without one.
+	for in, want := range map[string]string{"08:46:00Z": "08:46:00Z", "08:46:00+00:00": "08:46:00Z", "08:46:00": "08:46:00"} {
+		m.SeriesTime = in
+		tm, err := m.ParseSeriesTime()
+		if err != nil {
+			t.Fatal(err)
+		}
+		m.SetSeriesTime(tm)
+		if m.SeriesTime != want {
+			t.Fatalf("%s formatted as %q", in, m.SeriesTime)
+		}
+	}
+	m.SeriesDate = "2014-12-31Z"
+	if d, err = m.ParseSeriesDate(); err != nil {
+		t.Fatal(err)
+	}
+	if m.SetSeriesDate(d); m.SeriesDate != "2014-12-31Z" {
+		t.Fatalf("series date formatted as %q", m.SeriesDate)
+	}
+}
+
+func TestInvalidDateTime(t *testing.T) {
+	for _, s := range []*SubjectInformation{
+		{PatientBirthdate: ""},
+		{PatientBirthdate: "01/01/1988"},
+		{PatientBirthdate: "1988-13-01"},
+	} {
+		if _, err := s.ParsePatientBirthdate(); err == nil {
+			t.Fatalf("expected error parsing birthdate %q", s.PatientBirthdate)
+		}
+	}
+
+	s := &StudyInformation{StudyTime: "25:00:00"}
+	if _, err := s.ParseStudyTime(); err == nil {
+		t.Fatal("expected error parsing an invalid time")
+	}
+}
@@ -0,0 +1,218 @@
+package ismrmrd
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// Difference is a single value that differs between two headers. Path
+// addresses it by XML element names, e.g.
+// "encoding[0].encodedSpace.matrixSize.y". A and B hold the formatted
+// values, or "(absent)" where the element is missing from one header.
+type Difference struct {
+	Path string
+	A, B string
+}
+
+func (d Difference) String() string {
+	return fmt.Sprintf("%s: %s -> %s", d.Path, d.A, d.B)
+}
+
+const absent = "(absent)"
+
+// Diff compares two headers element by element and returns their
+// differences in document order. An element with children that is present
+// in only one header is reported once, written out as XML. Unknown
+// elements kept in Extensions are compared by position, e.g.
+// "extensions[1]", and also written out as XML; unknown attributes are
+// compared as "extensions.attrs".
+func Diff(a, b *IsmrmrdHeader) []Difference {
+	var diffs []Difference
+	diffValue("", reflect.ValueOf(a), reflect.ValueOf(b), &diffs)
+	return diffs
+}
+
+// diffValue compares a and b, either of which may be the zero Value to
+// mark an element missing from one side.
+func diffValue(path string, a, b reflect.Value, diffs *[]Difference) {
+	a, b = indirect(a), indirect(b)
+	if !a.IsValid() && !b.IsValid() {
+		return
+	}
+	var t reflect.Type
+	if a.IsValid() {
+		t = a.Type()
+	} else {
+		t = b.Type()
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		if t == reflect.TypeOf(Extensions{}) {
+			diffExtensions(path, a, b, diffs)
+			return
+		}
+		if !a.IsValid() || !b.IsValid() {
+			*diffs = append(*diffs, Difference{path, formatStruct(path, a), formatStruct(path, b)})
+			return
+		}
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			name := strings.Split(f.Tag.Get("xml"), ",")[0]
+			if f.Type == extensionsType {
+				name = "extensions"
+			} else if f.Name == "XMLName" || name == "-" || f.PkgPath != "" {
+				continue
+			}
+			if name == "" {
+				name = f.Name
+			}
+			if path != "" {
+				name = path + "." + name
+			}
+			diffValue(name, field(a, i), field(b, i), diffs)
+		}
+
+	case reflect.Slice:
+		n := length(a)
+		if m := length(b); m > n {
+			n = m
+		}
+		for i := 0; i < n; i++ {
+			diffValue(fmt.Sprintf("%s[%d]", path, i), index(a, i), index(b, i), diffs)
+		}
+
+	default:
+		if a.IsValid() && b.IsValid() && a.Interface() == b.Interface() {
+			return
+		}
+		*diffs = append(*diffs, Difference{path, format(a), format(b)})
+	}
+}
+
+func diffExtensions(path string, a, b reflect.Value, diffs *[]Difference) {
+	var ea, eb Extensions
+	if a.IsValid() {
+		ea = a.Interface().(Extensions)
+	}
+	if b.IsValid() {
+		eb = b.Interface().(Extensions)
+	}
+
+	n := len(ea.Elements)
+	if len(eb.Elements) > n {
+		n = len(eb.Elements)
+	}
+	for i := 0; i < n; i++ {
+		sa, sb := absent, absent
+		if i < len(ea.Elements) {
+			sa = formatUnknown(ea.Elements[i])
+		}
+		if i < len(eb.Elements) {
+			sb = formatUnknown(eb.Elements[i])
+		}
+		if sa != sb {
+			*diffs = append(*diffs, Difference{fmt.Sprintf("%s[%d]", path, i), sa, sb})
+		}
+	}
+
+	if sa, sb := formatAttrs(ea.Attrs), formatAttrs(eb.Attrs); sa != sb {
+		if sa == "" {
+			sa = absent
+		}
+		if sb == "" {
+			sb = absent
+		}
+		*diffs = append(*diffs, Difference{path + ".attrs", sa, sb})
+	}
+}
+
+// formatStruct writes v out as the XML element at path.
+func formatStruct(path string, v reflect.Value) string {
+	if !v.IsValid() {
+		return absent
+	}
+	name := path[strings.LastIndex(path, ".")+1:]
+	if i := strings.Index(name, "["); i >= 0 {
+		name = name[:i]
+	}
+
+	var buf bytes.Buffer
+	e := xml.NewEncoder(&buf)
+	if err := encodeStruct(e, xml.StartElement{Name: xml.Name{Local: name}}, v); err != nil {
+		return fmt.Sprintf("(%v)", err)
+	}
+	if err := e.Flush(); err != nil {
+		return fmt.Sprintf("(%v)", err)
+	}
+	return buf.String()
+}
+
+func formatUnknown(u UnknownElement) string {
+	name := u.XMLName.Local
+	if u.XMLName.Space != "" && u.XMLName.Space != Namespace {
+		name = "{" + u.XMLName.Space + "}" + name
+	}
+	attrs := formatAttrs(u.Attrs)
+	if attrs != "" {
+		attrs = " " + attrs
+	}
+	return fmt.Sprintf("<%s%s>%s</%s>", name, attrs, u.InnerXML, name)
+}
+
+func formatAttrs(attrs []xml.Attr) string {
+	s := make([]string, len(attrs))
+	for i, a := range attrs {
+		name := a.Name.Local
+		if a.Name.Space != "" {
+			name = a.Name.Space + ":" + name
+		}
+		s[i] = fmt.Sprintf("%s=%q", name, a.Value)
+	}
+	return strings.Join(s, " ")
+}
+
+func indirect(v reflect.Value) reflect.Value {
+	for v.IsValid() && v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return reflect.Value{}
+		}
+		v = v.Elem()
+	}
+	return v
+}
+
+func field(v reflect.Value, i int) reflect.Value {
+	if !v.IsValid() {
+		return v
+	}
+	return v.Field(i)
+}
+
+func length(v reflect.Value) int {
+	if !v.IsValid() {
+		return 0
+	}
+	return v.Len()
+}
+
+func index(v reflect.Value, i int) reflect.Value {
+	if !v.IsValid() || i >= v.Len() {
+		return reflect.Value{}
+	}
+	return v.Index(i)
+}
+
+func format(v reflect.Value) string {
+	if !v.IsValid() {
+		return absent
+	}
+	if v.Kind() == reflect.String {
+		return strconv.Quote(v.String())
+	}
+	return fmt.Sprint(v.Interface())
+}
@@ -0,0 +1,96 @@
+package ismrmrd
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDiff(t *testing.T) {
+	a, err := Deserialize([]byte(testXML))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diffs := Diff(a, testHeader); diffs != nil {
+		t.Fatalf("unexpected differences %v", diffs)
+	}
+
+	b, err := Deserialize([]byte(testXML))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b.Encoding[0].EncodedSpace.MatrixSize.Y = 256
+	b.SubjectInformation.PatientName = "Jane Doe"
+	b.SequenceParameters.TE = b.SequenceParameters.TE[:1]
+	b.Encoding[0].ParallelImaging = &ParallelImaging{
+		AccelerationFactor: AccelerationFactor{2, 1},
+		CalibrationMode:    "embedded",
+	}
+
+	want := []string{
+		`subjectInformation.patientName: "Joe Naegele" -> "Jane Doe"`,
+		`encoding[0].encodedSpace.matrixSize.y: 128 -> 256`,
+		`encoding[0].parallelImaging: (absent) -> <parallelImaging><accelerationFactor><kspace_encoding_step_1>2</kspace_encoding_step_1><kspace_encoding_step_2>1</kspace_encoding_step_2></accelerationFactor><calibrationMode>embedded</calibrationMode></parallelImaging>`,
+		`sequenceParameters.TE[1]: 0 -> (absent)`,
+	}
+	diffs := Diff(a, b)
+	if len(diffs) != len(want) {
+		t.Fatalf("expected %d differences, found %d: %v", len(want), len(diffs), diffs)
+	}
+	for i, w := range want {
+		if s := diffs[i].String(); s != w {
+			t.Fatalf("difference %d is %s, expected %s", i, s, w)
+		}
+	}
+}
+
+func TestDiffAddedElement(t *testing.T) {
+	a, err := Deserialize([]byte(testXML))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := Deserialize([]byte(testXML))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b.Encoding = append(b.Encoding, b.Encoding[0])
+
+	// An added element is reported once rather than field by field.
+	diffs := Diff(a, b)
+	if len(diffs) != 1 || diffs[0].Path != "encoding[1]" || diffs[0].A != absent ||
+		!strings.HasPrefix(diffs[0].B, "<encoding><encodedSpace>") {
+		t.Fatalf("unexpected differences %v", diffs)
+	}
+	if diffs := Diff(b, a); len(diffs) != 1 || diffs[0].B != absent {
+		t.Fatalf("unexpected differences %v", diffs)
+	}
+}
+
+func TestDiffExtensions(t *testing.T) {
+	a, err := Deserialize([]byte(extendedXML))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := Deserialize([]byte(strings.Replace(extendedXML, "<siemens:name>gre", "<siemens:name>epi", 1)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b.SubjectInformation.Extensions.Attrs = nil
+
+	want := []string{
+		`subjectInformation.extensions.attrs: source="scanner" -> (absent)`,
+		`extensions[1]: <{urn:siemens}protocol xmlns:siemens="urn:siemens">
+    <siemens:name>gre</siemens:name>
+  </{urn:siemens}protocol> -> <{urn:siemens}protocol xmlns:siemens="urn:siemens">
+    <siemens:name>epi</siemens:name>
+  </{urn:siemens}protocol>`,
+	}
+	diffs := Diff(a, b)
+	if len(diffs) != len(want) {
+		t.Fatalf("expected %d differences, found %d: %v", len(want), len(diffs), diffs)
+	}
+	for i, w := range want {
+		if s := diffs[i].String(); s != w {
+			t.Fatalf("difference %d is %s, expected %s", i, s, w)
+		}
+	}
+}
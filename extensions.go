@@ -0,0 +1,418 @@
+package ismrmrd
+
+import (
+	"encoding/xml"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// UnknownElement is an XML element that the header structs do not model,
+// kept verbatim so that it can be written back out. After and AfterIndex
+// record its position: it followed the AfterIndex'th (1-based) occurrence
+// of the known element After, or came before any known element if After
+// is empty.
+type UnknownElement struct {
+	XMLName    xml.Name   `json:"name"`
+	Attrs      []xml.Attr `xml:",any,attr" json:"attrs,omitempty"`
+	InnerXML   string     `xml:",innerxml" json:"innerXML"`
+	After      string     `xml:"-" json:"after,omitempty"`
+	AfterIndex int        `xml:"-" json:"afterIndex,omitempty"`
+}
+
+// Extensions holds the child elements and attributes of an element that
+// the schema does not describe. Only the header and its sections, such as
+// <subjectInformation> or <encoding>, keep them; small value types such as
+// MatrixSize and Limit do not, so that they stay comparable and can still
+// be written as positional literals. Rather than losing data, Deserialize
+// fails on unknown content it has nowhere to keep: child elements or
+// attributes of such value types, and attributes or child elements of
+// elements holding a single value, such as <patientName>.
+type Extensions struct {
+	Elements []UnknownElement `json:"elements,omitempty"`
+	Attrs    []xml.Attr       `json:"attrs,omitempty"`
+}
+
+// UnmarshalXML decodes the header, collecting unknown elements and
+// attributes into the Extensions of the enclosing struct.
+func (h *IsmrmrdHeader) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	h.XMLName = start.Name
+	return decodeStruct(d, start, reflect.ValueOf(h).Elem())
+}
+
+// MarshalXML encodes the header, writing unknown elements back out where
+// they were found.
+func (h IsmrmrdHeader) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	start.Name = h.XMLName
+	if start.Name.Local == "" {
+		start.Name = xml.Name{Space: Namespace, Local: "ismrmrdHeader"}
+	}
+	start.Attr = nil
+	return encodeStruct(e, start, reflect.ValueOf(h))
+}
+
+var extensionsType = reflect.TypeOf((*Extensions)(nil))
+
+// xmlField returns the element name and omitempty option of struct field
+// i, or an empty name for fields that are not child elements.
+func xmlField(t reflect.Type, i int) (name string, omitempty bool) {
+	f := t.Field(i)
+	if f.PkgPath != "" || f.Name == "XMLName" || f.Type == extensionsType {
+		return "", false
+	}
+	opts := strings.Split(f.Tag.Get("xml"), ",")
+	if opts[0] == "-" {
+		return "", false
+	}
+	name = opts[0]
+	if name == "" {
+		name = f.Name
+	}
+	for _, o := range opts[1:] {
+		if o == "omitempty" {
+			omitempty = true
+		}
+	}
+	return name, omitempty
+}
+
+func extensionsField(v reflect.Value) reflect.Value {
+	for i := 0; i < v.NumField(); i++ {
+		if v.Type().Field(i).Type == extensionsType {
+			return v.Field(i)
+		}
+	}
+	return reflect.Value{}
+}
+
+func decodeStruct(d *xml.Decoder, start xml.StartElement, v reflect.Value) error {
+	keep := extensionsField(v).IsValid()
+	ext := &Extensions{}
+	for _, a := range start.Attr {
+		// The default namespace is written back from the element name.
+		if a.Name.Space == "" && a.Name.Local == "xmlns" {
+			continue
+		}
+		if !keep {
+			if err := unpreservedAttr(start, a); err != nil {
+				return err
+			}
+			continue
+		}
+		ext.Attrs = append(ext.Attrs, a)
+	}
+
+	t := v.Type()
+	counts := map[string]int{}
+	last := ""
+	for {
+		tok, err := d.Token()
+		if err != nil {
+			return err
+		}
+
+		switch tok := tok.(type) {
+		case xml.StartElement:
+			field := -1
+			if tok.Name.Space == "" || tok.Name.Space == Namespace {
+				for i := 0; i < t.NumField(); i++ {
+					if name, _ := xmlField(t, i); name == tok.Name.Local {
+						field = i
+						break
+					}
+				}
+			}
+
+			if field < 0 {
+				if !keep {
+					return unpreservedElement(start, tok)
+				}
+				var u UnknownElement
+				if err := d.DecodeElement(&u, &tok); err != nil {
+					return err
+				}
+				u.After, u.AfterIndex = last, counts[last]
+				ext.Elements = append(ext.Elements, u)
+				continue
+			}
+
+			if err := decodeField(d, tok, v.Field(field)); err != nil {
+				return err
+			}
+			last = tok.Name.Local
+			counts[last]++
+
+		case xml.EndElement:
+			if f := extensionsField(v); f.IsValid() && (len(ext.Elements) > 0 || len(ext.Attrs) > 0) {
+				f.Set(reflect.ValueOf(ext))
+			}
+			return nil
+		}
+	}
+}
+
+func decodeField(d *xml.Decoder, start xml.StartElement, f reflect.Value) error {
+	switch f.Kind() {
+	case reflect.Ptr:
+		if f.IsNil() {
+			f.Set(reflect.New(f.Type().Elem()))
+		}
+		return decodeField(d, start, f.Elem())
+	case reflect.Slice:
+		elem := reflect.New(f.Type().Elem()).Elem()
+		if err := decodeField(d, start, elem); err != nil {
+			return err
+		}
+		f.Set(reflect.Append(f, elem))
+		return nil
+	case reflect.Struct:
+		return decodeStruct(d, start, f)
+	}
+	return decodeValue(d, start, f)
+}
+
+// decodeValue decodes an element holding a single value into f.
+func decodeValue(d *xml.Decoder, start xml.StartElement, f reflect.Value) error {
+	for _, a := range start.Attr {
+		if err := unpreservedAttr(start, a); err != nil {
+			return err
+		}
+	}
+
+	var text []byte
+	for {
+		tok, err := d.Token()
+		if err != nil {
+			return err
+		}
+		switch tok := tok.(type) {
+		case xml.CharData:
+			text = append(text, tok...)
+		case xml.StartElement:
+			return unpreservedElement(start, tok)
+		case xml.EndElement:
+			if err := setValue(f, string(text)); err != nil {
+				return fmt.Errorf("<%s>: %v", start.Name.Local, err)
+			}
+			return nil
+		}
+	}
+}
+
+// setValue parses s into v the way encoding/xml does.
+func setValue(v reflect.Value, s string) error {
+	if v.Kind() == reflect.String {
+		v.SetString(s)
+		return nil
+	}
+
+	s = strings.TrimSpace(s)
+	if s == "" {
+		v.Set(reflect.Zero(v.Type()))
+		return nil
+	}
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(s, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(s, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		n, err := strconv.ParseFloat(s, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetFloat(n)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	default:
+		return fmt.Errorf("cannot decode into %s", v.Type())
+	}
+	return nil
+}
+
+// unpreservedAttr returns an error for an attribute of start that there is
+// nowhere to keep. Namespace declarations carry no data of their own and
+// are ignored.
+func unpreservedAttr(start xml.StartElement, a xml.Attr) error {
+	if a.Name.Space == "xmlns" || (a.Name.Space == "" && a.Name.Local == "xmlns") {
+		return nil
+	}
+	return fmt.Errorf("attribute %s of <%s> cannot be preserved", a.Name.Local, start.Name.Local)
+}
+
+// unpreservedElement returns an error for an unknown child of start that
+// there is nowhere to keep.
+func unpreservedElement(start, child xml.StartElement) error {
+	return fmt.Errorf("unknown element <%s> in <%s> cannot be preserved", child.Name.Local, start.Name.Local)
+}
+
+func encodeStruct(e *xml.Encoder, start xml.StartElement, v reflect.Value) error {
+	var ext *Extensions
+	if f := extensionsField(v); f.IsValid() {
+		ext, _ = f.Interface().(*Extensions)
+	}
+	var written []bool
+	if ext != nil {
+		start.Attr = append(start.Attr, rawAttrs(ext.Attrs)...)
+		written = make([]bool, len(ext.Elements))
+	}
+
+	writeUnknown := func(after string, index int) error {
+		if ext == nil {
+			return nil
+		}
+		for i, u := range ext.Elements {
+			if !written[i] && u.After == after && u.AfterIndex == index {
+				written[i] = true
+				if err := encodeUnknown(e, u); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+
+	if err := e.EncodeToken(start); err != nil {
+		return err
+	}
+	if err := writeUnknown("", 0); err != nil {
+		return err
+	}
+
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		name, omitempty := xmlField(t, i)
+		if name == "" {
+			continue
+		}
+		child := xml.StartElement{Name: xml.Name{Local: name}}
+
+		f := v.Field(i)
+		switch {
+		case f.Kind() == reflect.Slice:
+			for j := 0; j < f.Len(); j++ {
+				if err := encodeField(e, child, f.Index(j)); err != nil {
+					return err
+				}
+				if err := writeUnknown(name, j+1); err != nil {
+					return err
+				}
+			}
+			continue
+		case f.Kind() == reflect.Ptr && f.IsNil():
+			continue
+		case omitempty && isZero(f):
+			continue
+		}
+
+		if err := encodeField(e, child, f); err != nil {
+			return err
+		}
+		if err := writeUnknown(name, 1); err != nil {
+			return err
+		}
+	}
+
+	// Elements whose neighbours have since been removed go last rather
+	// than being dropped.
+	for i, u := range ext.elements() {
+		if !written[i] {
+			if err := encodeUnknown(e, u); err != nil {
+				return err
+			}
+		}
+	}
+
+	return e.EncodeToken(start.End())
+}
+
+func (ext *Extensions) elements() []UnknownElement {
+	if ext == nil {
+		return nil
+	}
+	return ext.Elements
+}
+
+func encodeField(e *xml.Encoder, start xml.StartElement, f reflect.Value) error {
+	if f.Kind() == reflect.Ptr {
+		f = f.Elem()
+	}
+	if f.Kind() == reflect.Struct {
+		return encodeStruct(e, start, f)
+	}
+	return e.EncodeElement(f.Interface(), start)
+}
+
+func encodeUnknown(e *xml.Encoder, u UnknownElement) error {
+	start := xml.StartElement{Name: u.XMLName, Attr: rawAttrs(u.Attrs)}
+	if start.Name.Space == Namespace {
+		start.Name.Space = ""
+	}
+	// Keep the prefix if the element declares it itself.
+	for _, a := range u.Attrs {
+		if a.Name.Space == "xmlns" && a.Value == start.Name.Space {
+			start.Name = xml.Name{Local: a.Name.Local + ":" + start.Name.Local}
+			break
+		}
+	}
+	return e.EncodeElement(struct {
+		InnerXML string `xml:",innerxml"`
+	}{u.InnerXML}, start)
+}
+
+// rawAttrs prepares decoded attributes for encoding. The encoder does not
+// write namespace declarations or prefixes itself, so they are spelled out
+// in the attribute names.
+func rawAttrs(attrs []xml.Attr) []xml.Attr {
+	prefixes := map[string]string{}
+	for _, a := range attrs {
+		if a.Name.Space == "xmlns" {
+			prefixes[a.Value] = a.Name.Local
+		}
+	}
+
+	out := make([]xml.Attr, 0, len(attrs))
+	for _, a := range attrs {
+		switch {
+		case a.Name.Space == "" && a.Name.Local == "xmlns":
+			continue
+		case a.Name.Space == "xmlns":
+			a.Name = xml.Name{Local: "xmlns:" + a.Name.Local}
+		case a.Name.Space != "":
+			if p, ok := prefixes[a.Name.Space]; ok {
+				a.Name = xml.Name{Local: p + ":" + a.Name.Local}
+			}
+		}
+		out = append(out, a)
+	}
+	return out
+}
+
+func isZero(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.String:
+		return v.Len() == 0
+	case reflect.Bool:
+		return !v.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int() == 0
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return v.Uint() == 0
+	case reflect.Float32, reflect.Float64:
+		return v.Float() == 0
+	}
+	return false
+}
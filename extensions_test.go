@@ -0,0 +1,180 @@
+package ismrmrd
+
+import (
+	"strings"
+	"testing"
+)
+
+const extendedXML = `<ismrmrdHeader xmlns="http://www.ismrm.org/ISMRMRD" xmlns:xsi="http://www.w3.org/2001/XMLSchema-instance" xsi:schemaLocation="http://www.ismrm.org/ISMRMRD ismrmrd.xsd">
+  <vendorVersion>VE11C</vendorVersion>
+  <version>1</version>
+  <subjectInformation source="scanner">
+    <patientName>Anonymous</patientName>
+    <patientSize unit="cm">180</patientSize>
+    <patientWeight_kg>80</patientWeight_kg>
+    <patientID>42</patientID>
+    <patientBirthdate>1970-01-01</patientBirthdate>
+    <patientGender>O</patientGender>
+  </subjectInformation>
+  <experimentalConditions>
+    <H1resonanceFrequency_Hz>63500000</H1resonanceFrequency_Hz>
+  </experimentalConditions>
+  <encoding>
+    <encodedSpace>
+      <matrixSize>
+        <x>1</x>
+        <y>1</y>
+        <z>1</z>
+      </matrixSize>
+      <fieldOfView_mm>
+        <x>1</x>
+        <y>1</y>
+        <z>1</z>
+      </fieldOfView_mm>
+    </encodedSpace>
+    <reconSpace>
+      <matrixSize>
+        <x>1</x>
+        <y>1</y>
+        <z>1</z>
+      </matrixSize>
+      <fieldOfView_mm>
+        <x>1</x>
+        <y>1</y>
+        <z>1</z>
+      </fieldOfView_mm>
+    </reconSpace>
+    <encodingLimits></encodingLimits>
+    <trajectory>cartesian</trajectory>
+  </encoding>
+  <siemens:protocol xmlns:siemens="urn:siemens">
+    <siemens:name>gre</siemens:name>
+  </siemens:protocol>
+  <encoding>
+    <encodedSpace>
+      <matrixSize>
+        <x>2</x>
+        <y>2</y>
+        <z>2</z>
+      </matrixSize>
+      <fieldOfView_mm>
+        <x>2</x>
+        <y>2</y>
+        <z>2</z>
+      </fieldOfView_mm>
+    </encodedSpace>
+    <reconSpace>
+      <matrixSize>
+        <x>2</x>
+        <y>2</y>
+        <z>2</z>
+      </matrixSize>
+      <fieldOfView_mm>
+        <x>2</x>
+        <y>2</y>
+        <z>2</z>
+      </fieldOfView_mm>
+    </reconSpace>
+    <encodingLimits></encodingLimits>
+    <trajectory>radial</trajectory>
+  </encoding>
+</ismrmrdHeader>`
+
+func TestPreserveUnknownElements(t *testing.T) {
+	head, err := Deserialize([]byte(extendedXML))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if head.Version == nil || *head.Version != 1 || len(head.Encoding) != 2 || head.Encoding[1].Trajectory != "radial" ||
+		head.SubjectInformation.PatientGender != "O" {
+		t.Fatalf("known elements were not read (%+v)", head)
+	}
+	if head.Extensions == nil || len(head.Extensions.Elements) != 2 {
+		t.Fatalf("expected 2 unknown elements in the header, found %+v", head.Extensions)
+	}
+	if u := head.Extensions.Elements[1]; u.XMLName.Local != "protocol" || u.After != "encoding" || u.AfterIndex != 1 {
+		t.Fatalf("unknown element was not located (%+v)", u)
+	}
+
+	b, err := Serialize(head)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != extendedXML {
+		t.Fatalf("document changed after a round trip:\n%s", b)
+	}
+}
+
+func TestPreserveRemovedNeighbour(t *testing.T) {
+	head, err := Deserialize([]byte(extendedXML))
+	if err != nil {
+		t.Fatal(err)
+	}
+	head.Encoding = head.Encoding[:0]
+
+	b, err := Serialize(head)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(b), "<siemens:name>gre</siemens:name>") {
+		t.Fatalf("unknown element was dropped along with its neighbour:\n%s", b)
+	}
+}
+
+func TestPreserveThroughJSON(t *testing.T) {
+	head, err := Deserialize([]byte(extendedXML))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := SerializeJSON(head)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if head, err = DeserializeJSON(b); err != nil {
+		t.Fatal(err)
+	}
+
+	if b, err = Serialize(head); err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != extendedXML {
+		t.Fatalf("document changed after a JSON round trip:\n%s", b)
+	}
+}
+
+func TestValueTypesHaveNoExtensions(t *testing.T) {
+	// Value types stay comparable.
+	head, err := Deserialize([]byte(extendedXML))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := head.Encoding[1].EncodedSpace.MatrixSize; got != (MatrixSize{2, 2, 2}) {
+		t.Fatalf("matrix size read as %+v", got)
+	}
+	if head.Encoding[1].EncodedSpace != head.Encoding[1].ReconSpace {
+		t.Fatal("encoded and recon spaces should compare equal")
+	}
+}
+
+func TestUnpreservedContent(t *testing.T) {
+	// Unknown content that there is nowhere to keep is an error rather
+	// than being dropped.
+	for _, c := range []struct{ old, new, want string }{
+		{"<x>2</x>", "<x>2</x><unit>px</unit>", "unknown element <unit> in <matrixSize>"},
+		{"<matrixSize>", `<matrixSize unit="px">`, "attribute unit of <matrixSize>"},
+		{"<patientName>", `<patientName lang="en">`, "attribute lang of <patientName>"},
+		{"<patientName>Anonymous", "<patientName><given>Anon</given>", "unknown element <given> in <patientName>"},
+	} {
+		_, err := Deserialize([]byte(strings.Replace(extendedXML, c.old, c.new, 1)))
+		if err == nil || !strings.Contains(err.Error(), c.want) {
+			t.Fatalf("expected an error containing %q, got %v", c.want, err)
+		}
+	}
+
+	// Namespace declarations on value elements carry no data.
+	_, err := Deserialize([]byte(strings.Replace(extendedXML,
+		"<patientName>", `<patientName xmlns="http://www.ismrm.org/ISMRMRD">`, 1)))
+	if err != nil {
+		t.Fatal(err)
+	}
+}
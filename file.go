@@ -0,0 +1,127 @@
+package ismrmrd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/sbinet/go-hdf5"
+)
+
+// File is an HDF5 file holding one or more ISMRMRD dataset groups, for
+// example a noise scan stored alongside the main scan. Datasets obtained
+// from a File share its handle and must be closed before the File.
+type File struct {
+	file     *hdf5.File
+	filename string
+	readOnly bool
+}
+
+// OpenFile opens filename as specified by flag. CreateIfMissing applies to
+// the file only; groups are created explicitly with CreateDataset.
+func OpenFile(filename string, flag OpenFlag) (*File, error) {
+	readOnly := flag&ReadOnly != 0
+	if readOnly == (flag&ReadWrite != 0) {
+		return nil, fmt.Errorf("exactly one of ReadOnly or ReadWrite must be specified")
+	}
+	if readOnly && flag != ReadOnly {
+		return nil, fmt.Errorf("ReadOnly cannot be combined with other flags")
+	}
+
+	file, err := openHDF5File(filename, flag)
+	if err != nil {
+		return nil, err
+	}
+
+	return &File{file, filename, readOnly}, nil
+}
+
+func openHDF5File(filename string, flag OpenFlag) (*hdf5.File, error) {
+	if flag&ReadOnly != 0 {
+		return hdf5.OpenFile(filename, hdf5.F_ACC_RDONLY)
+	}
+
+	_, err := os.Stat(filename)
+	switch {
+	case os.IsNotExist(err):
+		if flag&CreateIfMissing == 0 {
+			return nil, err
+		}
+		return hdf5.CreateFile(filename, hdf5.F_ACC_EXCL)
+	case err != nil:
+		return nil, err
+	case flag&CreateIfMissing != 0 && flag&FailIfExists != 0:
+		return nil, fmt.Errorf("%s already exists", filename)
+	case flag&Truncate != 0:
+		return hdf5.CreateFile(filename, hdf5.F_ACC_TRUNC)
+	}
+	return hdf5.OpenFile(filename, hdf5.F_ACC_RDWR)
+}
+
+// Close closes the file.
+func (f *File) Close() error {
+	return f.file.Close()
+}
+
+// Groups returns the names of the top-level groups in the file that hold an
+// ISMRMRD dataset, that is an xml or data dataset. Other groups are left
+// out.
+func (f *File) Groups() ([]string, error) {
+	n, err := f.file.NumObjects()
+	if err != nil {
+		return nil, err
+	}
+
+	var groups []string
+	for i := uint(0); i < n; i++ {
+		otype, err := f.file.ObjectTypeByIndex(i)
+		if err != nil {
+			return nil, err
+		}
+		if otype != hdf5.H5G_GROUP {
+			continue
+		}
+		name, err := f.file.ObjectNameByIndex(i)
+		if err != nil {
+			return nil, err
+		}
+		if f.file.LinkExists(name+"/xml") || f.file.LinkExists(name+"/data") {
+			groups = append(groups, name)
+		}
+	}
+
+	return groups, nil
+}
+
+// OpenDataset opens the existing group groupname.
+func (f *File) OpenDataset(groupname string) (*Dataset, error) {
+	return f.openGroup(groupname, false)
+}
+
+// CreateDataset adds the group groupname to the file, leaving any other
+// groups intact. It fails if the group already exists.
+func (f *File) CreateDataset(groupname string) (*Dataset, error) {
+	if f.readOnly {
+		return nil, ErrReadOnly
+	}
+	if f.file.LinkExists(groupname) {
+		return nil, fmt.Errorf("group %s already exists in %s", groupname, f.filename)
+	}
+	return f.openGroup(groupname, true)
+}
+
+func (f *File) openGroup(groupname string, create bool) (*Dataset, error) {
+	var group *hdf5.Group
+	var err error
+	if f.file.LinkExists(groupname) {
+		group, err = f.file.OpenGroup(groupname)
+	} else if create && !f.readOnly {
+		group, err = f.file.CreateGroup(groupname)
+	} else {
+		err = fmt.Errorf("group %s does not exist in %s", groupname, f.filename)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &Dataset{file: f.file, groupname: groupname, group: group, readOnly: f.readOnly}, nil
+}
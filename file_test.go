@@ -0,0 +1,65 @@
+package ismrmrd
+
+import (
+	"os"
+	"testing"
+)
+
+func TestFileGroups(t *testing.T) {
+	dset, err := Create(filename, "noise")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(filename)
+	if err := dset.AppendAcquisition(&Acquisition{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := dset.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := OpenFile(filename, ReadWrite)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	scan, err := f.CreateDataset(groupname)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer scan.Close()
+	if _, err := f.CreateDataset(groupname); err == nil {
+		t.Fatal("expected error creating an existing group")
+	}
+
+	if err := scan.WriteHeader(testHeader); err != nil {
+		t.Fatal(err)
+	}
+	other, err := f.file.CreateGroup("other")
+	if err != nil {
+		t.Fatal(err)
+	}
+	other.Close()
+
+	groups, err := f.Groups()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(groups) != 2 || (groups[0] != groupname && groups[1] != groupname) {
+		t.Fatalf("expected the noise and %s groups, found %v", groupname, groups)
+	}
+
+	noise, err := f.OpenDataset("noise")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer noise.Close()
+	if n := noise.NumberOfAcquisitions(); n != 1 {
+		t.Fatalf("existing group lost its acquisitions (found %d)", n)
+	}
+
+	if _, err := f.OpenDataset("missing"); err == nil {
+		t.Fatal("expected error opening a missing group")
+	}
+}
@@ -1,56 +1,252 @@
 package ismrmrd
 
+import (
+	"fmt"
+	"strings"
+)
+
+// AcquisitionFlag is the 1-based bit position of a flag in
+// AcquisitionHeader.Flags.
+type AcquisitionFlag uint
+
+// AcquisitionFlags is a set of acquisition flags, as stored in
+// AcquisitionHeader.Flags.
+type AcquisitionFlags uint64
+
+// ImageFlag is the 1-based bit position of a flag in ImageHeader.Flags.
+type ImageFlag uint
+
+// ImageFlags is a set of image flags, as stored in ImageHeader.Flags.
+type ImageFlags uint64
+
 // Acquisition Flags
 const (
-	ACQ_FIRST_IN_ENCODE_STEP1               = 1
-	ACQ_LAST_IN_ENCODE_STEP1                = 2
-	ACQ_FIRST_IN_ENCODE_STEP2               = 3
-	ACQ_LAST_IN_ENCODE_STEP2                = 4
-	ACQ_FIRST_IN_AVERAGE                    = 5
-	ACQ_LAST_IN_AVERAGE                     = 6
-	ACQ_FIRST_IN_SLICE                      = 7
-	ACQ_LAST_IN_SLICE                       = 8
-	ACQ_FIRST_IN_CONTRAST                   = 9
-	ACQ_LAST_IN_CONTRAST                    = 10
-	ACQ_FIRST_IN_PHASE                      = 11
-	ACQ_LAST_IN_PHASE                       = 12
-	ACQ_FIRST_IN_REPETITION                 = 13
-	ACQ_LAST_IN_REPETITION                  = 14
-	ACQ_FIRST_IN_SET                        = 15
-	ACQ_LAST_IN_SET                         = 16
-	ACQ_FIRST_IN_SEGMENT                    = 17
-	ACQ_LAST_IN_SEGMENT                     = 18
-	ACQ_IS_NOISE_MEASUREMENT                = 19
-	ACQ_IS_PARALLEL_CALIBRATION             = 20
-	ACQ_IS_PARALLEL_CALIBRATION_AND_IMAGING = 21
-	ACQ_IS_REVERSE                          = 22
-	ACQ_IS_NAVIGATION_DATA                  = 23
-	ACQ_IS_PHASECORR_DATA                   = 24
-	ACQ_LAST_IN_MEASUREMENT                 = 25
-	ACQ_IS_HPFEEDBACK_DATA                  = 26
-	ACQ_IS_DUMMYSCAN_DATA                   = 27
-	ACQ_IS_RTFEEDBACK_DATA                  = 28
-	ACQ_IS_SURFACECOILCORRECTIONSCAN_DATA   = 29
-
-	ACQ_USER1 = 57
-	ACQ_USER2 = 58
-	ACQ_USER3 = 59
-	ACQ_USER4 = 60
-	ACQ_USER5 = 61
-	ACQ_USER6 = 62
-	ACQ_USER7 = 63
-	ACQ_USER8 = 64
+	ACQ_FIRST_IN_ENCODE_STEP1               AcquisitionFlag = 1
+	ACQ_LAST_IN_ENCODE_STEP1                AcquisitionFlag = 2
+	ACQ_FIRST_IN_ENCODE_STEP2               AcquisitionFlag = 3
+	ACQ_LAST_IN_ENCODE_STEP2                AcquisitionFlag = 4
+	ACQ_FIRST_IN_AVERAGE                    AcquisitionFlag = 5
+	ACQ_LAST_IN_AVERAGE                     AcquisitionFlag = 6
+	ACQ_FIRST_IN_SLICE                      AcquisitionFlag = 7
+	ACQ_LAST_IN_SLICE                       AcquisitionFlag = 8
+	ACQ_FIRST_IN_CONTRAST                   AcquisitionFlag = 9
+	ACQ_LAST_IN_CONTRAST                    AcquisitionFlag = 10
+	ACQ_FIRST_IN_PHASE                      AcquisitionFlag = 11
+	ACQ_LAST_IN_PHASE                       AcquisitionFlag = 12
+	ACQ_FIRST_IN_REPETITION                 AcquisitionFlag = 13
+	ACQ_LAST_IN_REPETITION                  AcquisitionFlag = 14
+	ACQ_FIRST_IN_SET                        AcquisitionFlag = 15
+	ACQ_LAST_IN_SET                         AcquisitionFlag = 16
+	ACQ_FIRST_IN_SEGMENT                    AcquisitionFlag = 17
+	ACQ_LAST_IN_SEGMENT                     AcquisitionFlag = 18
+	ACQ_IS_NOISE_MEASUREMENT                AcquisitionFlag = 19
+	ACQ_IS_PARALLEL_CALIBRATION             AcquisitionFlag = 20
+	ACQ_IS_PARALLEL_CALIBRATION_AND_IMAGING AcquisitionFlag = 21
+	ACQ_IS_REVERSE                          AcquisitionFlag = 22
+	ACQ_IS_NAVIGATION_DATA                  AcquisitionFlag = 23
+	ACQ_IS_PHASECORR_DATA                   AcquisitionFlag = 24
+	ACQ_LAST_IN_MEASUREMENT                 AcquisitionFlag = 25
+	ACQ_IS_HPFEEDBACK_DATA                  AcquisitionFlag = 26
+	ACQ_IS_DUMMYSCAN_DATA                   AcquisitionFlag = 27
+	ACQ_IS_RTFEEDBACK_DATA                  AcquisitionFlag = 28
+	ACQ_IS_SURFACECOILCORRECTIONSCAN_DATA   AcquisitionFlag = 29
+
+	ACQ_USER1 AcquisitionFlag = 57
+	ACQ_USER2 AcquisitionFlag = 58
+	ACQ_USER3 AcquisitionFlag = 59
+	ACQ_USER4 AcquisitionFlag = 60
+	ACQ_USER5 AcquisitionFlag = 61
+	ACQ_USER6 AcquisitionFlag = 62
+	ACQ_USER7 AcquisitionFlag = 63
+	ACQ_USER8 AcquisitionFlag = 64
 )
 
 // Image Flags
 const (
-	IMAGE_IS_NAVIGATION_DATA = 1
-	IMAGE_USER1              = 57
-	IMAGE_USER2              = 58
-	IMAGE_USER3              = 59
-	IMAGE_USER4              = 60
-	IMAGE_USER5              = 61
-	IMAGE_USER6              = 62
-	IMAGE_USER7              = 63
-	IMAGE_USER8              = 64
+	IMAGE_IS_NAVIGATION_DATA ImageFlag = 1
+	IMAGE_USER1              ImageFlag = 57
+	IMAGE_USER2              ImageFlag = 58
+	IMAGE_USER3              ImageFlag = 59
+	IMAGE_USER4              ImageFlag = 60
+	IMAGE_USER5              ImageFlag = 61
+	IMAGE_USER6              ImageFlag = 62
+	IMAGE_USER7              ImageFlag = 63
+	IMAGE_USER8              ImageFlag = 64
 )
+
+var acquisitionFlagNames = map[AcquisitionFlag]string{
+	ACQ_FIRST_IN_ENCODE_STEP1:               "FIRST_IN_ENCODE_STEP1",
+	ACQ_LAST_IN_ENCODE_STEP1:                "LAST_IN_ENCODE_STEP1",
+	ACQ_FIRST_IN_ENCODE_STEP2:               "FIRST_IN_ENCODE_STEP2",
+	ACQ_LAST_IN_ENCODE_STEP2:                "LAST_IN_ENCODE_STEP2",
+	ACQ_FIRST_IN_AVERAGE:                    "FIRST_IN_AVERAGE",
+	ACQ_LAST_IN_AVERAGE:                     "LAST_IN_AVERAGE",
+	ACQ_FIRST_IN_SLICE:                      "FIRST_IN_SLICE",
+	ACQ_LAST_IN_SLICE:                       "LAST_IN_SLICE",
+	ACQ_FIRST_IN_CONTRAST:                   "FIRST_IN_CONTRAST",
+	ACQ_LAST_IN_CONTRAST:                    "LAST_IN_CONTRAST",
+	ACQ_FIRST_IN_PHASE:                      "FIRST_IN_PHASE",
+	ACQ_LAST_IN_PHASE:                       "LAST_IN_PHASE",
+	ACQ_FIRST_IN_REPETITION:                 "FIRST_IN_REPETITION",
+	ACQ_LAST_IN_REPETITION:                  "LAST_IN_REPETITION",
+	ACQ_FIRST_IN_SET:                        "FIRST_IN_SET",
+	ACQ_LAST_IN_SET:                         "LAST_IN_SET",
+	ACQ_FIRST_IN_SEGMENT:                    "FIRST_IN_SEGMENT",
+	ACQ_LAST_IN_SEGMENT:                     "LAST_IN_SEGMENT",
+	ACQ_IS_NOISE_MEASUREMENT:                "IS_NOISE_MEASUREMENT",
+	ACQ_IS_PARALLEL_CALIBRATION:             "IS_PARALLEL_CALIBRATION",
+	ACQ_IS_PARALLEL_CALIBRATION_AND_IMAGING: "IS_PARALLEL_CALIBRATION_AND_IMAGING",
+	ACQ_IS_REVERSE:                          "IS_REVERSE",
+	ACQ_IS_NAVIGATION_DATA:                  "IS_NAVIGATION_DATA",
+	ACQ_IS_PHASECORR_DATA:                   "IS_PHASECORR_DATA",
+	ACQ_LAST_IN_MEASUREMENT:                 "LAST_IN_MEASUREMENT",
+	ACQ_IS_HPFEEDBACK_DATA:                  "IS_HPFEEDBACK_DATA",
+	ACQ_IS_DUMMYSCAN_DATA:                   "IS_DUMMYSCAN_DATA",
+	ACQ_IS_RTFEEDBACK_DATA:                  "IS_RTFEEDBACK_DATA",
+	ACQ_IS_SURFACECOILCORRECTIONSCAN_DATA:   "IS_SURFACECOILCORRECTIONSCAN_DATA",
+	ACQ_USER1:                               "USER1",
+	ACQ_USER2:                               "USER2",
+	ACQ_USER3:                               "USER3",
+	ACQ_USER4:                               "USER4",
+	ACQ_USER5:                               "USER5",
+	ACQ_USER6:                               "USER6",
+	ACQ_USER7:                               "USER7",
+	ACQ_USER8:                               "USER8",
+}
+
+var imageFlagNames = map[ImageFlag]string{
+	IMAGE_IS_NAVIGATION_DATA: "IS_NAVIGATION_DATA",
+	IMAGE_USER1:              "USER1",
+	IMAGE_USER2:              "USER2",
+	IMAGE_USER3:              "USER3",
+	IMAGE_USER4:              "USER4",
+	IMAGE_USER5:              "USER5",
+	IMAGE_USER6:              "USER6",
+	IMAGE_USER7:              "USER7",
+	IMAGE_USER8:              "USER8",
+}
+
+// bit returns the mask for a 1-based bit position, or 0 if pos is out of
+// range.
+func bit(pos uint) uint64 {
+	if pos < 1 || pos > 64 {
+		return 0
+	}
+	return 1 << (pos - 1)
+}
+
+// flagNames lists the names of the bits set in flags. Bits without a name
+// are listed by position, e.g. "BIT40".
+func flagNames(flags uint64, name func(pos uint) (string, bool)) []string {
+	names := []string{}
+	for pos := uint(1); pos <= 64; pos++ {
+		if flags&bit(pos) == 0 {
+			continue
+		}
+		if n, ok := name(pos); ok {
+			names = append(names, n)
+		} else {
+			names = append(names, fmt.Sprintf("BIT%d", pos))
+		}
+	}
+	return names
+}
+
+// parseFlagNames is the inverse of flagNames.
+func parseFlagNames(names []string, pos func(name string) (uint, bool)) (uint64, error) {
+	var flags uint64
+	for _, name := range names {
+		p, ok := pos(name)
+		if !ok {
+			if _, err := fmt.Sscanf(name, "BIT%d", &p); err != nil || bit(p) == 0 {
+				return 0, fmt.Errorf("unknown flag %q", name)
+			}
+		}
+		flags |= bit(p)
+	}
+	return flags, nil
+}
+
+func flagString(flags uint64, name func(pos uint) (string, bool)) string {
+	if flags == 0 {
+		return "0"
+	}
+	return strings.Join(flagNames(flags, name), "|")
+}
+
+// String returns the name of the flag, e.g. "IS_REVERSE" for
+// ACQ_IS_REVERSE, or "BIT<n>" for an unnamed bit.
+func (f AcquisitionFlag) String() string {
+	if name, ok := acquisitionFlagNames[f]; ok {
+		return name
+	}
+	return fmt.Sprintf("BIT%d", f)
+}
+
+// String returns the name of the flag, or "BIT<n>" for an unnamed bit.
+func (f ImageFlag) String() string {
+	if name, ok := imageFlagNames[f]; ok {
+		return name
+	}
+	return fmt.Sprintf("BIT%d", f)
+}
+
+func acquisitionFlagName(pos uint) (string, bool) {
+	name, ok := acquisitionFlagNames[AcquisitionFlag(pos)]
+	return name, ok
+}
+
+func imageFlagName(pos uint) (string, bool) {
+	name, ok := imageFlagNames[ImageFlag(pos)]
+	return name, ok
+}
+
+// String lists the names of the flags set, e.g. "FIRST_IN_SLICE|IS_REVERSE".
+func (f AcquisitionFlags) String() string {
+	return flagString(uint64(f), acquisitionFlagName)
+}
+
+// String lists the names of the flags set, e.g. "IS_NAVIGATION_DATA|USER1".
+func (f ImageFlags) String() string {
+	return flagString(uint64(f), imageFlagName)
+}
+
+// IsFlagSet reports whether flag, e.g. ACQ_IS_REVERSE, is set.
+func (h *AcquisitionHeader) IsFlagSet(flag AcquisitionFlag) bool {
+	return h.Flags&bit(uint(flag)) != 0
+}
+
+// SetFlag sets a flag.
+func (h *AcquisitionHeader) SetFlag(flag AcquisitionFlag) {
+	h.Flags |= bit(uint(flag))
+}
+
+// ClearFlag clears a flag.
+func (h *AcquisitionHeader) ClearFlag(flag AcquisitionFlag) {
+	h.Flags &^= bit(uint(flag))
+}
+
+// ClearAllFlags clears every flag.
+func (h *AcquisitionHeader) ClearAllFlags() {
+	h.Flags = 0
+}
+
+// IsFlagSet reports whether flag, e.g. IMAGE_IS_NAVIGATION_DATA, is set.
+func (h *ImageHeader) IsFlagSet(flag ImageFlag) bool {
+	return h.Flags&bit(uint(flag)) != 0
+}
+
+// SetFlag sets a flag.
+func (h *ImageHeader) SetFlag(flag ImageFlag) {
+	h.Flags |= bit(uint(flag))
+}
+
+// ClearFlag clears a flag.
+func (h *ImageHeader) ClearFlag(flag ImageFlag) {
+	h.Flags &^= bit(uint(flag))
+}
+
+// ClearAllFlags clears every flag.
+func (h *ImageHeader) ClearAllFlags() {
+	h.Flags = 0
+}
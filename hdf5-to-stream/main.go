@@ -0,0 +1,99 @@
+// Command hdf5-to-stream writes an ISMRMRD dataset to stdout as an MRD
+// stream, so that it can be piped into stream-based tools.
+package main
+
+import (
+	"bufio"
+	"flag"
+	"log"
+	"os"
+
+	"github.com/naegelejd/go-ismrmrd"
+)
+
+func main() {
+	filename := flag.String("file", "", "input HDF5 file")
+	groupname := flag.String("group", "dataset", "dataset group within the file")
+	flag.Parse()
+
+	if *filename == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	dset, err := ismrmrd.OpenDataset(*filename, *groupname, ismrmrd.ReadOnly)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer dset.Close()
+
+	out := bufio.NewWriter(os.Stdout)
+	if err := convert(dset, ismrmrd.NewStreamWriter(out)); err != nil {
+		log.Fatal(err)
+	}
+	if err := out.Flush(); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// convert writes the header, acquisitions, waveforms, images and arrays
+// held by dset, followed by a close message.
+func convert(dset *ismrmrd.Dataset, w *ismrmrd.StreamWriter) error {
+	contents, err := dset.Contents()
+	if err != nil {
+		return err
+	}
+
+	if contents.HasXMLHeader {
+		xml, err := dset.ReadXMLHeader()
+		if err != nil {
+			return err
+		}
+		if err := w.WriteXMLHeader(xml); err != nil {
+			return err
+		}
+	}
+
+	err = dset.EachAcquisition(func(i int, acq *ismrmrd.Acquisition) error {
+		return w.WriteAcquisition(acq)
+	})
+	if err != nil {
+		return err
+	}
+
+	for i := 0; i < contents.NumberOfWaveforms; i++ {
+		wav, err := dset.ReadWaveform(i)
+		if err != nil {
+			return err
+		}
+		if err := w.WriteWaveform(wav); err != nil {
+			return err
+		}
+	}
+
+	for _, series := range contents.Images {
+		for i := 0; i < series.Count; i++ {
+			img, err := dset.ReadImage(series.Name, i)
+			if err != nil {
+				return err
+			}
+			if err := w.WriteImage(img); err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, variable := range contents.Arrays {
+		for i := 0; i < variable.Count; i++ {
+			arr, err := dset.ReadArray(variable.Name, i)
+			if err != nil {
+				return err
+			}
+			if err := w.WriteArray(arr); err != nil {
+				return err
+			}
+		}
+	}
+
+	return w.WriteClose()
+}
@@ -0,0 +1,174 @@
+package ismrmrd
+
+// #cgo LDFLAGS: -lhdf5
+// #include <stdlib.h>
+// #include <hdf5.h>
+import "C"
+
+import (
+	"fmt"
+	"unsafe"
+
+	"github.com/sbinet/go-hdf5"
+)
+
+// go-hdf5 always reads and writes using the dataset's file datatype as the
+// memory datatype. The ISMRMRD C library stores its headers with the packed
+// (#pragma pack(2)) layout, which does not match Go's struct alignment, so
+// the helpers below call into HDF5 directly with an explicit memory type and
+// let HDF5 convert between the two layouts by member name.
+
+// hvl mirrors hvl_t, HDF5's in-memory representation of a variable-length
+// sequence.
+type hvl struct {
+	len uintptr
+	p   unsafe.Pointer
+}
+
+func spaceID(space *hdf5.Dataspace) C.hid_t {
+	if space == nil {
+		return C.H5S_ALL
+	}
+	return C.hid_t(space.ID())
+}
+
+func readWithType(dset *hdf5.Dataset, mtype *hdf5.Datatype, memspace, filespace *hdf5.Dataspace, buf unsafe.Pointer) error {
+	rc := C.H5Dread(C.hid_t(dset.ID()), C.hid_t(mtype.ID()),
+		spaceID(memspace), spaceID(filespace), C.H5P_DEFAULT, buf)
+	if rc < 0 {
+		return fmt.Errorf("failed to read dataset")
+	}
+	return nil
+}
+
+func writeWithType(dset *hdf5.Dataset, mtype *hdf5.Datatype, memspace, filespace *hdf5.Dataspace, buf unsafe.Pointer) error {
+	rc := C.H5Dwrite(C.hid_t(dset.ID()), C.hid_t(mtype.ID()),
+		spaceID(memspace), spaceID(filespace), C.H5P_DEFAULT, buf)
+	if rc < 0 {
+		return fmt.Errorf("failed to write dataset")
+	}
+	return nil
+}
+
+// reclaim frees the variable-length buffers HDF5 allocated while reading
+// into buf.
+func reclaim(mtype *hdf5.Datatype, memspace *hdf5.Dataspace, buf unsafe.Pointer) {
+	C.H5Dvlen_reclaim(C.hid_t(mtype.ID()), spaceID(memspace), C.H5P_DEFAULT, buf)
+}
+
+// newFloatsVL copies src into C memory so it can be handed to HDF5 inside a
+// compound without violating the cgo pointer rules. It must be released
+// with freeVL.
+func newFloatsVL(src []float32) hvl {
+	if len(src) == 0 {
+		return hvl{}
+	}
+	p := C.malloc(C.size_t(len(src)) * C.size_t(unsafe.Sizeof(src[0])))
+	copy((*[1 << 30]float32)(p)[:len(src):len(src)], src)
+	return hvl{uintptr(len(src)), p}
+}
+
+// newComplexVL copies src into C memory as interleaved real/imaginary
+// floats.
+func newComplexVL(src []complex64) hvl {
+	if len(src) == 0 {
+		return hvl{}
+	}
+	p := C.malloc(C.size_t(len(src)) * C.size_t(unsafe.Sizeof(src[0])))
+	copy((*[1 << 29]complex64)(p)[:len(src):len(src)], src)
+	return hvl{uintptr(2 * len(src)), p}
+}
+
+// newUint32VL copies src into C memory. It must be released with freeVL.
+func newUint32VL(src []uint32) hvl {
+	if len(src) == 0 {
+		return hvl{}
+	}
+	p := C.malloc(C.size_t(len(src)) * C.size_t(unsafe.Sizeof(src[0])))
+	copy((*[1 << 30]uint32)(p)[:len(src):len(src)], src)
+	return hvl{uintptr(len(src)), p}
+}
+
+func freeVL(v hvl) {
+	if v.p != nil {
+		C.free(v.p)
+	}
+}
+
+func (v hvl) floats() []float32 {
+	if v.len == 0 {
+		return nil
+	}
+	out := make([]float32, v.len)
+	copy(out, (*[1 << 30]float32)(v.p)[:v.len:v.len])
+	return out
+}
+
+func (v hvl) uint32s() []uint32 {
+	if v.len == 0 {
+		return nil
+	}
+	out := make([]uint32, v.len)
+	copy(out, (*[1 << 30]uint32)(v.p)[:v.len:v.len])
+	return out
+}
+
+func (v hvl) complexes() []complex64 {
+	n := v.len / 2
+	if n == 0 {
+		return nil
+	}
+	out := make([]complex64, n)
+	copy(out, (*[1 << 29]complex64)(v.p)[:n:n])
+	return out
+}
+
+// setShuffle adds the byte-shuffle filter to a dataset creation property
+// list.
+func setShuffle(dcpl *hdf5.PropList) error {
+	if C.H5Pset_shuffle(C.hid_t(dcpl.ID())) < 0 {
+		return fmt.Errorf("failed to enable shuffle filter")
+	}
+	return nil
+}
+
+// dataPointer returns the address of the first element of a slice produced
+// by makeData.
+func dataPointer(data interface{}) unsafe.Pointer {
+	switch v := data.(type) {
+	case []uint16:
+		return unsafe.Pointer(&v[0])
+	case []int16:
+		return unsafe.Pointer(&v[0])
+	case []uint32:
+		return unsafe.Pointer(&v[0])
+	case []int32:
+		return unsafe.Pointer(&v[0])
+	case []float32:
+		return unsafe.Pointer(&v[0])
+	case []float64:
+		return unsafe.Pointer(&v[0])
+	case []complex64:
+		return unsafe.Pointer(&v[0])
+	case []complex128:
+		return unsafe.Pointer(&v[0])
+	}
+	panic(fmt.Sprintf("unsupported data type %T", data))
+}
+
+// newCString returns a C copy of s for use as an HDF5 variable-length
+// string. It must be released with freeCString.
+func newCString(s string) unsafe.Pointer {
+	return unsafe.Pointer(C.CString(s))
+}
+
+func freeCString(p unsafe.Pointer) {
+	C.free(p)
+}
+
+func goString(p unsafe.Pointer) string {
+	if p == nil {
+		return ""
+	}
+	return C.GoString((*C.char)(p))
+}
@@ -0,0 +1,233 @@
+package ismrmrd
+
+import (
+	"fmt"
+	"unsafe"
+
+	"github.com/sbinet/go-hdf5"
+)
+
+// compound accumulates the members of an HDF5 compound datatype, deferring
+// error checks until done is called.
+type compound struct {
+	t   *hdf5.CompoundType
+	err error
+}
+
+func newCompound(size uintptr) *compound {
+	t, err := hdf5.NewCompoundType(int(size))
+	return &compound{t, err}
+}
+
+func (c *compound) insert(name string, offset uintptr, dtype *hdf5.Datatype) {
+	if c.err != nil {
+		return
+	}
+	c.err = c.t.Insert(name, int(offset), dtype)
+}
+
+// insertOwned inserts a member type built by one of the helpers below and
+// releases it, since HDF5 keeps its own copy.
+func (c *compound) insertOwned(name string, offset uintptr, dtype *hdf5.Datatype, err error) {
+	if c.err != nil {
+		return
+	}
+	if err != nil {
+		c.err = err
+		return
+	}
+	c.insert(name, offset, dtype)
+	dtype.Close()
+}
+
+func (c *compound) array(name string, offset uintptr, base *hdf5.Datatype, n int) {
+	if c.err != nil {
+		return
+	}
+	t, err := hdf5.NewArrayType(base, []int{n})
+	if err != nil {
+		c.err = err
+		return
+	}
+	c.insertOwned(name, offset, &t.Datatype, nil)
+}
+
+func (c *compound) done() (*hdf5.Datatype, error) {
+	if c.err != nil {
+		if c.t != nil {
+			c.t.Close()
+		}
+		return nil, c.err
+	}
+	return &c.t.Datatype, nil
+}
+
+func encodingCountersType() (*hdf5.Datatype, error) {
+	var e EncodingCounters
+	c := newCompound(unsafe.Sizeof(e))
+	c.insert("kspace_encode_step_1", unsafe.Offsetof(e.KSpaceEncodeStep1), hdf5.T_NATIVE_UINT16)
+	c.insert("kspace_encode_step_2", unsafe.Offsetof(e.KSpaceEncodeStep2), hdf5.T_NATIVE_UINT16)
+	c.insert("average", unsafe.Offsetof(e.Average), hdf5.T_NATIVE_UINT16)
+	c.insert("slice", unsafe.Offsetof(e.Slice), hdf5.T_NATIVE_UINT16)
+	c.insert("contrast", unsafe.Offsetof(e.Contrast), hdf5.T_NATIVE_UINT16)
+	c.insert("phase", unsafe.Offsetof(e.Phase), hdf5.T_NATIVE_UINT16)
+	c.insert("repetition", unsafe.Offsetof(e.Repetition), hdf5.T_NATIVE_UINT16)
+	c.insert("set", unsafe.Offsetof(e.Set), hdf5.T_NATIVE_UINT16)
+	c.insert("segment", unsafe.Offsetof(e.Segment), hdf5.T_NATIVE_UINT16)
+	c.array("user", unsafe.Offsetof(e.User), hdf5.T_NATIVE_UINT16, ISMRMRD_USER_INTS)
+	return c.done()
+}
+
+func acquisitionHeaderType() (*hdf5.Datatype, error) {
+	var h AcquisitionHeader
+	c := newCompound(unsafe.Sizeof(h))
+	c.insert("version", unsafe.Offsetof(h.Version), hdf5.T_NATIVE_UINT16)
+	c.insert("flags", unsafe.Offsetof(h.Flags), hdf5.T_NATIVE_UINT64)
+	c.insert("measurement_uid", unsafe.Offsetof(h.MeasurementUID), hdf5.T_NATIVE_UINT32)
+	c.insert("scan_counter", unsafe.Offsetof(h.ScanCounter), hdf5.T_NATIVE_UINT32)
+	c.insert("acquisition_time_stamp", unsafe.Offsetof(h.AcquisitionTimeStamp), hdf5.T_NATIVE_UINT32)
+	c.array("physiology_time_stamp", unsafe.Offsetof(h.PhysiologyTimeStamp), hdf5.T_NATIVE_UINT32, ISMRMRD_PHYS_STAMPS)
+	c.insert("number_of_samples", unsafe.Offsetof(h.NumberOfSamples), hdf5.T_NATIVE_UINT16)
+	c.insert("available_channels", unsafe.Offsetof(h.AvailableChannels), hdf5.T_NATIVE_UINT16)
+	c.insert("active_channels", unsafe.Offsetof(h.ActiveChannels), hdf5.T_NATIVE_UINT16)
+	c.array("channel_mask", unsafe.Offsetof(h.ChannelMask), hdf5.T_NATIVE_UINT64, ISMRMRD_CHANNEL_MASKS)
+	c.insert("discard_pre", unsafe.Offsetof(h.DiscardPre), hdf5.T_NATIVE_UINT16)
+	c.insert("discard_post", unsafe.Offsetof(h.DiscardPost), hdf5.T_NATIVE_UINT16)
+	c.insert("center_sample", unsafe.Offsetof(h.CenterSample), hdf5.T_NATIVE_UINT16)
+	c.insert("encoding_space_ref", unsafe.Offsetof(h.EncodingSpaceRef), hdf5.T_NATIVE_UINT16)
+	c.insert("trajectory_dimensions", unsafe.Offsetof(h.TrajectoryDimensions), hdf5.T_NATIVE_UINT16)
+	c.insert("sample_time_us", unsafe.Offsetof(h.SampleTimeUs), hdf5.T_NATIVE_FLOAT)
+	c.array("position", unsafe.Offsetof(h.Position), hdf5.T_NATIVE_FLOAT, ISMRMRD_POSITION_LENGTH)
+	c.array("read_dir", unsafe.Offsetof(h.ReadDirection), hdf5.T_NATIVE_FLOAT, ISMRMRD_DIRECTION_LENGTH)
+	c.array("phase_dir", unsafe.Offsetof(h.PhaseDirection), hdf5.T_NATIVE_FLOAT, ISMRMRD_DIRECTION_LENGTH)
+	c.array("slice_dir", unsafe.Offsetof(h.SliceDirection), hdf5.T_NATIVE_FLOAT, ISMRMRD_DIRECTION_LENGTH)
+	c.array("patient_table_position", unsafe.Offsetof(h.PatientablePosition), hdf5.T_NATIVE_FLOAT, ISMRMRD_POSITION_LENGTH)
+	idx, err := encodingCountersType()
+	c.insertOwned("idx", unsafe.Offsetof(h.Idx), idx, err)
+	c.array("user_int", unsafe.Offsetof(h.UserInt), hdf5.T_NATIVE_INT32, ISMRMRD_USER_INTS)
+	c.array("user_float", unsafe.Offsetof(h.UserFloat32), hdf5.T_NATIVE_FLOAT, ISMRMRD_USER_FLOATS)
+	return c.done()
+}
+
+func varLenType(base *hdf5.Datatype) (*hdf5.Datatype, error) {
+	t, err := hdf5.NewVarLenType(base)
+	if err != nil {
+		return nil, err
+	}
+	return &t.Datatype, nil
+}
+
+// hdf5Acquisition is the in-memory layout of one element of the
+// <group>/data compound dataset.
+type hdf5Acquisition struct {
+	Head AcquisitionHeader
+	Traj hvl
+	Data hvl
+}
+
+func acquisitionType() (*hdf5.Datatype, error) {
+	var a hdf5Acquisition
+	c := newCompound(unsafe.Sizeof(a))
+	head, err := acquisitionHeaderType()
+	c.insertOwned("head", unsafe.Offsetof(a.Head), head, err)
+	traj, err := varLenType(hdf5.T_NATIVE_FLOAT)
+	c.insertOwned("traj", unsafe.Offsetof(a.Traj), traj, err)
+	data, err := varLenType(hdf5.T_NATIVE_FLOAT)
+	c.insertOwned("data", unsafe.Offsetof(a.Data), data, err)
+	return c.done()
+}
+
+func waveformHeaderType() (*hdf5.Datatype, error) {
+	var h WaveformHeader
+	c := newCompound(unsafe.Sizeof(h))
+	c.insert("version", unsafe.Offsetof(h.Version), hdf5.T_NATIVE_UINT16)
+	c.insert("flags", unsafe.Offsetof(h.Flags), hdf5.T_NATIVE_UINT64)
+	c.insert("measurement_uid", unsafe.Offsetof(h.MeasurementUID), hdf5.T_NATIVE_UINT32)
+	c.insert("scan_counter", unsafe.Offsetof(h.ScanCounter), hdf5.T_NATIVE_UINT32)
+	c.insert("time_stamp", unsafe.Offsetof(h.TimeStamp), hdf5.T_NATIVE_UINT32)
+	c.insert("number_of_samples", unsafe.Offsetof(h.NumberOfSamples), hdf5.T_NATIVE_UINT16)
+	c.insert("channels", unsafe.Offsetof(h.Channels), hdf5.T_NATIVE_UINT16)
+	c.insert("sample_time_us", unsafe.Offsetof(h.SampleTimeUs), hdf5.T_NATIVE_FLOAT)
+	c.insert("waveform_id", unsafe.Offsetof(h.WaveformID), hdf5.T_NATIVE_UINT16)
+	return c.done()
+}
+
+// hdf5Waveform is the in-memory layout of one element of the
+// <group>/waveforms compound dataset.
+type hdf5Waveform struct {
+	Head WaveformHeader
+	Data hvl
+}
+
+func waveformType() (*hdf5.Datatype, error) {
+	var w hdf5Waveform
+	c := newCompound(unsafe.Sizeof(w))
+	head, err := waveformHeaderType()
+	c.insertOwned("head", unsafe.Offsetof(w.Head), head, err)
+	data, err := varLenType(hdf5.T_NATIVE_UINT32)
+	c.insertOwned("data", unsafe.Offsetof(w.Data), data, err)
+	return c.done()
+}
+
+func imageHeaderType() (*hdf5.Datatype, error) {
+	var h ImageHeader
+	c := newCompound(unsafe.Sizeof(h))
+	c.insert("version", unsafe.Offsetof(h.Version), hdf5.T_NATIVE_UINT16)
+	c.insert("data_type", unsafe.Offsetof(h.DataType), hdf5.T_NATIVE_UINT16)
+	c.insert("flags", unsafe.Offsetof(h.Flags), hdf5.T_NATIVE_UINT64)
+	c.insert("measurement_uid", unsafe.Offsetof(h.MeasurementUID), hdf5.T_NATIVE_UINT32)
+	c.array("matrix_size", unsafe.Offsetof(h.MatrixSize), hdf5.T_NATIVE_UINT16, 3)
+	c.array("field_of_view", unsafe.Offsetof(h.FieldOfView), hdf5.T_NATIVE_FLOAT, 3)
+	c.insert("channels", unsafe.Offsetof(h.Channels), hdf5.T_NATIVE_UINT16)
+	c.array("position", unsafe.Offsetof(h.Position), hdf5.T_NATIVE_FLOAT, ISMRMRD_POSITION_LENGTH)
+	c.array("read_dir", unsafe.Offsetof(h.ReadDirection), hdf5.T_NATIVE_FLOAT, ISMRMRD_DIRECTION_LENGTH)
+	c.array("phase_dir", unsafe.Offsetof(h.PhaseDirection), hdf5.T_NATIVE_FLOAT, ISMRMRD_DIRECTION_LENGTH)
+	c.array("slice_dir", unsafe.Offsetof(h.SliceDirection), hdf5.T_NATIVE_FLOAT, ISMRMRD_DIRECTION_LENGTH)
+	c.array("patient_table_position", unsafe.Offsetof(h.PatientTablePosition), hdf5.T_NATIVE_FLOAT, ISMRMRD_POSITION_LENGTH)
+	c.insert("average", unsafe.Offsetof(h.Average), hdf5.T_NATIVE_UINT16)
+	c.insert("slice", unsafe.Offsetof(h.Slice), hdf5.T_NATIVE_UINT16)
+	c.insert("contrast", unsafe.Offsetof(h.Contrast), hdf5.T_NATIVE_UINT16)
+	c.insert("phase", unsafe.Offsetof(h.Phase), hdf5.T_NATIVE_UINT16)
+	c.insert("repetition", unsafe.Offsetof(h.Repetition), hdf5.T_NATIVE_UINT16)
+	c.insert("set", unsafe.Offsetof(h.Set), hdf5.T_NATIVE_UINT16)
+	c.insert("acquisition_time_stamp", unsafe.Offsetof(h.AcquisitionTimeStamp), hdf5.T_NATIVE_UINT32)
+	c.array("physiology_time_stamp", unsafe.Offsetof(h.PhysiologyTimeStamp), hdf5.T_NATIVE_UINT32, ISMRMRD_PHYS_STAMPS)
+	c.insert("image_type", unsafe.Offsetof(h.ImageType), hdf5.T_NATIVE_UINT16)
+	c.insert("image_index", unsafe.Offsetof(h.ImageIndex), hdf5.T_NATIVE_UINT16)
+	c.insert("image_series_index", unsafe.Offsetof(h.ImageSeriesIndex), hdf5.T_NATIVE_UINT16)
+	c.array("user_int", unsafe.Offsetof(h.UserInt), hdf5.T_NATIVE_INT32, ISMRMRD_USER_INTS)
+	c.array("user_float", unsafe.Offsetof(h.UserFloat), hdf5.T_NATIVE_FLOAT, ISMRMRD_USER_FLOATS)
+	c.insert("attribute_string_len", unsafe.Offsetof(h.AttributeStringLen), hdf5.T_NATIVE_UINT32)
+	return c.done()
+}
+
+func complexType(size uintptr, part *hdf5.Datatype) (*hdf5.Datatype, error) {
+	c := newCompound(size)
+	c.insert("real", 0, part)
+	c.insert("imag", size/2, part)
+	return c.done()
+}
+
+// elementType returns a new HDF5 datatype for the ISMRMRD data type code.
+// Complex types are stored as {real, imag} compounds, as in the C library.
+func elementType(dataType uint16) (*hdf5.Datatype, error) {
+	switch dataType {
+	case ISMRMRD_USHORT:
+		return hdf5.T_NATIVE_UINT16.Copy()
+	case ISMRMRD_SHORT:
+		return hdf5.T_NATIVE_INT16.Copy()
+	case ISMRMRD_UINT:
+		return hdf5.T_NATIVE_UINT32.Copy()
+	case ISMRMRD_INT:
+		return hdf5.T_NATIVE_INT32.Copy()
+	case ISMRMRD_FLOAT:
+		return hdf5.T_NATIVE_FLOAT.Copy()
+	case ISMRMRD_DOUBLE:
+		return hdf5.T_NATIVE_DOUBLE.Copy()
+	case ISMRMRD_CXFLOAT:
+		return complexType(unsafe.Sizeof(complex64(0)), hdf5.T_NATIVE_FLOAT)
+	case ISMRMRD_CXDOUBLE:
+		return complexType(unsafe.Sizeof(complex128(0)), hdf5.T_NATIVE_DOUBLE)
+	}
+	return nil, fmt.Errorf("invalid data type %d", dataType)
+}
@@ -0,0 +1,51 @@
+// Command header-diff prints the differences between the XML headers of two
+// ISMRMRD datasets. It exits with status 1 if the headers differ.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/naegelejd/go-ismrmrd"
+)
+
+func readHeader(filename, groupname string) *ismrmrd.IsmrmrdHeader {
+	dset, err := ismrmrd.OpenDataset(filename, groupname, ismrmrd.ReadOnly)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer dset.Close()
+
+	head, err := dset.ReadHeader()
+	if err != nil {
+		log.Fatalf("%s: %v", filename, err)
+	}
+	return head
+}
+
+func main() {
+	groupname := flag.String("group", "dataset", "dataset group within each file")
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: %s [-group name] a.h5 b.h5\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() != 2 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	a := readHeader(flag.Arg(0), *groupname)
+	b := readHeader(flag.Arg(1), *groupname)
+
+	diffs := ismrmrd.Diff(a, b)
+	for _, d := range diffs {
+		fmt.Println(d)
+	}
+	if len(diffs) > 0 {
+		os.Exit(1)
+	}
+}
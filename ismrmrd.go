@@ -1,5 +1,7 @@
 package ismrmrd
 
+import "fmt"
+
 const (
 	ISMRMRD_VERSION_MAJOR = 1
 	ISMRMRD_VERSION_MINOR = 2
@@ -30,43 +32,43 @@ const (
 )
 
 type EncodingCounters struct {
-	KSpaceEncodeStep1 uint16
-	KSpaceEncodeStep2 uint16
-	Average           uint16
-	Slice             uint16
-	Contrast          uint16
-	Phase             uint16
-	Repetition        uint16
-	Set               uint16
-	Segment           uint16
-	User              [ISMRMRD_USER_INTS]uint16
+	KSpaceEncodeStep1 uint16                    `json:"kspace_encode_step_1"`
+	KSpaceEncodeStep2 uint16                    `json:"kspace_encode_step_2"`
+	Average           uint16                    `json:"average"`
+	Slice             uint16                    `json:"slice"`
+	Contrast          uint16                    `json:"contrast"`
+	Phase             uint16                    `json:"phase"`
+	Repetition        uint16                    `json:"repetition"`
+	Set               uint16                    `json:"set"`
+	Segment           uint16                    `json:"segment"`
+	User              [ISMRMRD_USER_INTS]uint16 `json:"user"`
 }
 
 type AcquisitionHeader struct {
-	Version              uint16
-	Flags                uint64
-	MeasurementUID       uint32
-	ScanCounter          uint32
-	AcquisitionTimeStamp uint32
-	PhysiologyTimeStamp  [ISMRMRD_PHYS_STAMPS]uint32
-	NumberOfSamples      uint16
-	AvailableChannels    uint16
-	ActiveChannels       uint16
-	ChannelMask          [ISMRMRD_CHANNEL_MASKS]uint64
-	DiscardPre           uint16
-	DiscardPost          uint16
-	CenterSample         uint16
-	EncodingSpaceRef     uint16
-	TrajectoryDimensions uint16
-	SampleTimeUs         float32
-	Position             [ISMRMRD_POSITION_LENGTH]float32
-	ReadDirection        [ISMRMRD_DIRECTION_LENGTH]float32
-	PhaseDirection       [ISMRMRD_DIRECTION_LENGTH]float32
-	SliceDirection       [ISMRMRD_DIRECTION_LENGTH]float32
-	PatientablePosition  [ISMRMRD_POSITION_LENGTH]float32
-	Idx                  EncodingCounters
-	UserInt              [ISMRMRD_USER_INTS]int32
-	UserFloat32          [ISMRMRD_USER_FLOATS]float32
+	Version              uint16                            `json:"version"`
+	Flags                uint64                            `json:"flags"`
+	MeasurementUID       uint32                            `json:"measurement_uid"`
+	ScanCounter          uint32                            `json:"scan_counter"`
+	AcquisitionTimeStamp uint32                            `json:"acquisition_time_stamp"`
+	PhysiologyTimeStamp  [ISMRMRD_PHYS_STAMPS]uint32       `json:"physiology_time_stamp"`
+	NumberOfSamples      uint16                            `json:"number_of_samples"`
+	AvailableChannels    uint16                            `json:"available_channels"`
+	ActiveChannels       uint16                            `json:"active_channels"`
+	ChannelMask          [ISMRMRD_CHANNEL_MASKS]uint64     `json:"channel_mask"`
+	DiscardPre           uint16                            `json:"discard_pre"`
+	DiscardPost          uint16                            `json:"discard_post"`
+	CenterSample         uint16                            `json:"center_sample"`
+	EncodingSpaceRef     uint16                            `json:"encoding_space_ref"`
+	TrajectoryDimensions uint16                            `json:"trajectory_dimensions"`
+	SampleTimeUs         float32                           `json:"sample_time_us"`
+	Position             [ISMRMRD_POSITION_LENGTH]float32  `json:"position"`
+	ReadDirection        [ISMRMRD_DIRECTION_LENGTH]float32 `json:"read_dir"`
+	PhaseDirection       [ISMRMRD_DIRECTION_LENGTH]float32 `json:"phase_dir"`
+	SliceDirection       [ISMRMRD_DIRECTION_LENGTH]float32 `json:"slice_dir"`
+	PatientablePosition  [ISMRMRD_POSITION_LENGTH]float32  `json:"patient_table_position"`
+	Idx                  EncodingCounters                  `json:"idx"`
+	UserInt              [ISMRMRD_USER_INTS]int32          `json:"user_int"`
+	UserFloat32          [ISMRMRD_USER_FLOATS]float32      `json:"user_float"`
 }
 
 type Acquisition struct {
@@ -76,30 +78,195 @@ type Acquisition struct {
 }
 
 type ImageHeader struct {
-	Version              uint16
-	DataType             uint16
-	Flags                uint64
-	MeasurementUID       uint32
-	MatrixSize           [3]uint16
-	FieldOfView          [3]float32
-	Channels             uint16
-	Position             [ISMRMRD_POSITION_LENGTH]float32
-	ReadDirection        [ISMRMRD_DIRECTION_LENGTH]float32
-	PhaseDirection       [ISMRMRD_DIRECTION_LENGTH]float32
-	SliceDirection       [ISMRMRD_DIRECTION_LENGTH]float32
-	PatientTablePosition [ISMRMRD_POSITION_LENGTH]float32
-	Average              uint16
-	Slice                uint16
-	Contrast             uint16
-	Phase                uint16
-	Repetition           uint16
-	Set                  uint16
-	AcquisitionTimeStamp uint32
-	PhysiologyTimeStamp  [ISMRMRD_PHYS_STAMPS]uint32
-	ImageType            uint16
-	ImageIndex           uint16
-	ImageSeriesIndex     uint16
-	UserInt              [ISMRMRD_USER_INTS]int32
-	UserFloat            [ISMRMRD_USER_FLOATS]float32
-	AttributeStringLen   uint32
+	Version              uint16                            `json:"version"`
+	DataType             uint16                            `json:"data_type"`
+	Flags                uint64                            `json:"flags"`
+	MeasurementUID       uint32                            `json:"measurement_uid"`
+	MatrixSize           [3]uint16                         `json:"matrix_size"`
+	FieldOfView          [3]float32                        `json:"field_of_view"`
+	Channels             uint16                            `json:"channels"`
+	Position             [ISMRMRD_POSITION_LENGTH]float32  `json:"position"`
+	ReadDirection        [ISMRMRD_DIRECTION_LENGTH]float32 `json:"read_dir"`
+	PhaseDirection       [ISMRMRD_DIRECTION_LENGTH]float32 `json:"phase_dir"`
+	SliceDirection       [ISMRMRD_DIRECTION_LENGTH]float32 `json:"slice_dir"`
+	PatientTablePosition [ISMRMRD_POSITION_LENGTH]float32  `json:"patient_table_position"`
+	Average              uint16                            `json:"average"`
+	Slice                uint16                            `json:"slice"`
+	Contrast             uint16                            `json:"contrast"`
+	Phase                uint16                            `json:"phase"`
+	Repetition           uint16                            `json:"repetition"`
+	Set                  uint16                            `json:"set"`
+	AcquisitionTimeStamp uint32                            `json:"acquisition_time_stamp"`
+	PhysiologyTimeStamp  [ISMRMRD_PHYS_STAMPS]uint32       `json:"physiology_time_stamp"`
+	ImageType            uint16                            `json:"image_type"`
+	ImageIndex           uint16                            `json:"image_index"`
+	ImageSeriesIndex     uint16                            `json:"image_series_index"`
+	UserInt              [ISMRMRD_USER_INTS]int32          `json:"user_int"`
+	UserFloat            [ISMRMRD_USER_FLOATS]float32      `json:"user_float"`
+	AttributeStringLen   uint32                            `json:"attribute_string_len"`
+}
+
+type WaveformHeader struct {
+	Version         uint16  `json:"version"`
+	Flags           uint64  `json:"flags"`
+	MeasurementUID  uint32  `json:"measurement_uid"`
+	ScanCounter     uint32  `json:"scan_counter"`
+	TimeStamp       uint32  `json:"time_stamp"`
+	NumberOfSamples uint16  `json:"number_of_samples"`
+	Channels        uint16  `json:"channels"`
+	SampleTimeUs    float32 `json:"sample_time_us"`
+	WaveformID      uint16  `json:"waveform_id"`
+}
+
+// Waveform holds a physiological or gradient waveform. Data contains
+// NumberOfSamples samples for each channel, one channel after another.
+type Waveform struct {
+	Head WaveformHeader
+	Data []uint32
+}
+
+// Image holds a reconstructed image. Data is a slice whose element type
+// corresponds to Head.DataType ([]uint16 for ISMRMRD_USHORT through
+// []complex128 for ISMRMRD_CXDOUBLE), ordered with x varying fastest,
+// followed by y, z and channel.
+type Image struct {
+	Head       ImageHeader
+	Attributes string
+	Data       interface{}
+}
+
+// NewImage returns an image of the given data type and dimensions with
+// zeroed pixel data.
+func NewImage(dataType uint16, x, y, z, channels uint16) (*Image, error) {
+	img := &Image{}
+	img.Head.Version = ISMRMRD_VERSION_MAJOR
+	img.Head.DataType = dataType
+	img.Head.MatrixSize = [3]uint16{x, y, z}
+	img.Head.Channels = channels
+
+	data, err := makeData(dataType, img.NumberOfElements())
+	if err != nil {
+		return nil, err
+	}
+	img.Data = data
+
+	return img, nil
+}
+
+// NumberOfElements returns the number of pixels described by the image
+// header, across all channels.
+func (img *Image) NumberOfElements() int {
+	h := &img.Head
+	return int(h.MatrixSize[0]) * int(h.MatrixSize[1]) * int(h.MatrixSize[2]) * int(h.Channels)
+}
+
+// makeData allocates a slice of n elements of the Go type corresponding to
+// the ISMRMRD data type code.
+func makeData(dataType uint16, n int) (interface{}, error) {
+	if n < 0 {
+		return nil, fmt.Errorf("invalid number of elements %d", n)
+	}
+	switch dataType {
+	case ISMRMRD_USHORT:
+		return make([]uint16, n), nil
+	case ISMRMRD_SHORT:
+		return make([]int16, n), nil
+	case ISMRMRD_UINT:
+		return make([]uint32, n), nil
+	case ISMRMRD_INT:
+		return make([]int32, n), nil
+	case ISMRMRD_FLOAT:
+		return make([]float32, n), nil
+	case ISMRMRD_DOUBLE:
+		return make([]float64, n), nil
+	case ISMRMRD_CXFLOAT:
+		return make([]complex64, n), nil
+	case ISMRMRD_CXDOUBLE:
+		return make([]complex128, n), nil
+	}
+	return nil, fmt.Errorf("invalid data type %d", dataType)
+}
+
+// dataTypeSize returns the size in bytes of one element of the ISMRMRD
+// data type.
+func dataTypeSize(dataType uint16) (int, error) {
+	switch dataType {
+	case ISMRMRD_USHORT, ISMRMRD_SHORT:
+		return 2, nil
+	case ISMRMRD_UINT, ISMRMRD_INT, ISMRMRD_FLOAT:
+		return 4, nil
+	case ISMRMRD_DOUBLE, ISMRMRD_CXFLOAT:
+		return 8, nil
+	case ISMRMRD_CXDOUBLE:
+		return 16, nil
+	}
+	return 0, fmt.Errorf("invalid data type %d", dataType)
+}
+
+// dataTypeOf returns the ISMRMRD data type code and length of a slice
+// produced by makeData.
+func dataTypeOf(data interface{}) (uint16, int, error) {
+	switch v := data.(type) {
+	case []uint16:
+		return ISMRMRD_USHORT, len(v), nil
+	case []int16:
+		return ISMRMRD_SHORT, len(v), nil
+	case []uint32:
+		return ISMRMRD_UINT, len(v), nil
+	case []int32:
+		return ISMRMRD_INT, len(v), nil
+	case []float32:
+		return ISMRMRD_FLOAT, len(v), nil
+	case []float64:
+		return ISMRMRD_DOUBLE, len(v), nil
+	case []complex64:
+		return ISMRMRD_CXFLOAT, len(v), nil
+	case []complex128:
+		return ISMRMRD_CXDOUBLE, len(v), nil
+	}
+	return 0, 0, fmt.Errorf("unsupported data type %T", data)
+}
+
+// NDArray is an array of up to ISMRMRD_NDARRAY_MAXDIM dimensions. Dims lists
+// the extent of each dimension, fastest varying first, and Data is a slice
+// whose element type corresponds to DataType, as for Image.
+type NDArray struct {
+	Version  uint16
+	DataType uint16
+	Dims     []uint
+	Data     interface{}
+}
+
+// NewNDArray returns an array of the given data type and dimensions with
+// zeroed data.
+func NewNDArray(dataType uint16, dims ...uint) (*NDArray, error) {
+	if len(dims) == 0 || len(dims) > ISMRMRD_NDARRAY_MAXDIM {
+		return nil, fmt.Errorf("invalid number of dimensions %d", len(dims))
+	}
+
+	arr := &NDArray{
+		Version:  ISMRMRD_VERSION_MAJOR,
+		DataType: dataType,
+		Dims:     append([]uint(nil), dims...),
+	}
+
+	data, err := makeData(dataType, arr.NumberOfElements())
+	if err != nil {
+		return nil, err
+	}
+	arr.Data = data
+
+	return arr, nil
+}
+
+// NumberOfElements returns the number of elements described by Dims.
+func (arr *NDArray) NumberOfElements() int {
+	if len(arr.Dims) == 0 {
+		return 0
+	}
+	n := 1
+	for _, d := range arr.Dims {
+		n *= int(d)
+	}
+	return n
 }
@@ -0,0 +1,106 @@
+package ismrmrd
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// SerializeJSON encodes head as JSON, using the XML element names as keys.
+func SerializeJSON(head *IsmrmrdHeader) ([]byte, error) {
+	return json.MarshalIndent(head, "", "  ")
+}
+
+// DeserializeJSON decodes a header produced by SerializeJSON.
+func DeserializeJSON(data []byte) (*IsmrmrdHeader, error) {
+	var head IsmrmrdHeader
+	err := json.Unmarshal(data, &head)
+	return &head, err
+}
+
+func acquisitionFlagPos(name string) (uint, bool) {
+	for f, n := range acquisitionFlagNames {
+		if n == name {
+			return uint(f), true
+		}
+	}
+	return 0, false
+}
+
+func imageFlagPos(name string) (uint, bool) {
+	for f, n := range imageFlagNames {
+		if n == name {
+			return uint(f), true
+		}
+	}
+	return 0, false
+}
+
+// MarshalJSON encodes the header with its flags as a list of names, e.g.
+// ["FIRST_IN_SLICE","IS_REVERSE"], and its channel mask as a list of active
+// channel indices.
+func (h AcquisitionHeader) MarshalJSON() ([]byte, error) {
+	type plain AcquisitionHeader
+	chans := h.ActiveChannelIndices()
+	if chans == nil {
+		chans = []uint16{}
+	}
+	return json.Marshal(struct {
+		plain
+		Flags       []string `json:"flags"`
+		ChannelMask []uint16 `json:"channel_mask"`
+	}{plain(h), flagNames(h.Flags, acquisitionFlagName), chans})
+}
+
+func (h *AcquisitionHeader) UnmarshalJSON(b []byte) error {
+	type plain AcquisitionHeader
+	v := struct {
+		*plain
+		Flags       []string `json:"flags"`
+		ChannelMask []uint16 `json:"channel_mask"`
+	}{plain: (*plain)(h)}
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+
+	flags, err := parseFlagNames(v.Flags, acquisitionFlagPos)
+	if err != nil {
+		return err
+	}
+	h.Flags = flags
+
+	h.SetAllChannelsNotActive()
+	for _, ch := range v.ChannelMask {
+		if int(ch) >= ISMRMRD_MAX_CHANNELS {
+			return fmt.Errorf("channel %d is beyond the channel mask", ch)
+		}
+		h.SetChannelActive(ch)
+	}
+	return nil
+}
+
+// MarshalJSON encodes the header with its flags as a list of names.
+func (h ImageHeader) MarshalJSON() ([]byte, error) {
+	type plain ImageHeader
+	return json.Marshal(struct {
+		plain
+		Flags []string `json:"flags"`
+	}{plain(h), flagNames(h.Flags, imageFlagName)})
+}
+
+func (h *ImageHeader) UnmarshalJSON(b []byte) error {
+	type plain ImageHeader
+	v := struct {
+		*plain
+		Flags []string `json:"flags"`
+	}{plain: (*plain)(h)}
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+
+	flags, err := parseFlagNames(v.Flags, imageFlagPos)
+	if err != nil {
+		return err
+	}
+	h.Flags = flags
+	return nil
+}
@@ -0,0 +1,116 @@
+package ismrmrd
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestHeaderJSON(t *testing.T) {
+	head, err := Deserialize([]byte(modernXML))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := SerializeJSON(head)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(b, []byte(`"patientHeight_m": 1.8`)) {
+		t.Fatalf("expected JSON keys to follow the XML element names:\n%s", b)
+	}
+
+	again, err := DeserializeJSON(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	x1, err := Serialize(head)
+	if err != nil {
+		t.Fatal(err)
+	}
+	x2, err := Serialize(again)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(x1, x2) {
+		t.Fatalf("XML changed after a JSON round trip:\n%s\n%s", x1, x2)
+	}
+
+	b, err = SerializeJSON(testHeader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again, err = DeserializeJSON(b); err != nil {
+		t.Fatal(err)
+	}
+	if !equal(again, testHeader) {
+		t.Fatal("header changed after a JSON round trip")
+	}
+}
+
+func TestAcquisitionHeaderJSON(t *testing.T) {
+	var h AcquisitionHeader
+	h.ScanCounter = 12
+	h.SetFlag(ACQ_FIRST_IN_SLICE)
+	h.SetFlag(ACQ_IS_REVERSE)
+	h.Flags |= 1 << 39
+	h.ActiveChannels = 2
+	h.SetChannelActive(0)
+	h.SetChannelActive(65)
+	h.Idx.User[7] = 3
+	h.UserFloat32[1] = 0.5
+
+	b, err := json.Marshal(h)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []string{
+		`"flags":["FIRST_IN_SLICE","IS_REVERSE","BIT40"]`,
+		`"channel_mask":[0,65]`,
+		`"scan_counter":12`,
+	} {
+		if !strings.Contains(string(b), s) {
+			t.Fatalf("expected %s in %s", s, b)
+		}
+	}
+
+	var got AcquisitionHeader
+	if err := json.Unmarshal(b, &got); err != nil {
+		t.Fatal(err)
+	}
+	if got != h {
+		t.Fatalf("header changed after a JSON round trip (%+v)", got)
+	}
+
+	if err := json.Unmarshal([]byte(`{"flags":["NOT_A_FLAG"]}`), &got); err == nil {
+		t.Fatal("expected error decoding an unknown flag")
+	}
+	if err := json.Unmarshal([]byte(`{"channel_mask":[1024]}`), &got); err == nil {
+		t.Fatal("expected error decoding a channel beyond the mask")
+	}
+}
+
+func TestImageHeaderJSON(t *testing.T) {
+	var h ImageHeader
+	h.DataType = ISMRMRD_CXFLOAT
+	h.MatrixSize = [3]uint16{64, 32, 1}
+	h.SetFlag(IMAGE_IS_NAVIGATION_DATA)
+	h.SetFlag(IMAGE_USER2)
+
+	b, err := json.Marshal(h)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(b), `"flags":["IS_NAVIGATION_DATA","USER2"]`) {
+		t.Fatalf("expected named flags in %s", b)
+	}
+
+	var got ImageHeader
+	if err := json.Unmarshal(b, &got); err != nil {
+		t.Fatal(err)
+	}
+	if got != h {
+		t.Fatalf("header changed after a JSON round trip (%+v)", got)
+	}
+}
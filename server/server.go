@@ -0,0 +1,324 @@
+// Package server implements reconstruction services that speak the MRD
+// streaming protocol.
+//
+// Each connection receives a config, an XML header and then a stream of
+// acquisitions and waveforms, which are pushed through a chain of handlers.
+// Images and arrays emitted by the last handler are sent back to the client.
+// When the client sends its close message, every handler is finished in
+// turn, the server replies with its own close message and the connection is
+// closed.
+package server
+
+import (
+	"bufio"
+	"fmt"
+	"log"
+	"net"
+	"runtime/debug"
+	"sync"
+
+	"github.com/naegelejd/go-ismrmrd"
+)
+
+// Emit passes an item to the next handler in the chain. Items are
+// *ismrmrd.Acquisition, *ismrmrd.Waveform, *ismrmrd.Image, *ismrmrd.NDArray
+// or, for text messages returned to the client, string.
+type Emit func(item interface{}) error
+
+// Handler is one stage of a reconstruction chain. Handlers are created per
+// connection and called from a single goroutine.
+type Handler interface {
+	// Handle processes one item, passing any results to next.
+	Handle(c *Conn, item interface{}, next Emit) error
+	// Finish is called when the client closes the stream, so that
+	// handlers buffering data can emit their results.
+	Finish(c *Conn, next Emit) error
+}
+
+// HandlerFunc adapts a function to a Handler that buffers nothing.
+type HandlerFunc func(c *Conn, item interface{}, next Emit) error
+
+func (f HandlerFunc) Handle(c *Conn, item interface{}, next Emit) error {
+	return f(c, item, next)
+}
+
+func (f HandlerFunc) Finish(c *Conn, next Emit) error {
+	return nil
+}
+
+// Conn holds the state of one client session.
+type Conn struct {
+	// ConfigFile and ConfigText hold whichever config the client sent.
+	ConfigFile string
+	ConfigText string
+	// Header is the parsed XML header; XMLHeader is the raw document.
+	Header    *ismrmrd.IsmrmrdHeader
+	XMLHeader string
+
+	conn  net.Conn
+	buf   *bufio.Writer
+	w     *ismrmrd.StreamWriter
+	chain []Handler
+	emits []Emit
+}
+
+// RemoteAddr returns the address of the client.
+func (c *Conn) RemoteAddr() net.Addr {
+	return c.conn.RemoteAddr()
+}
+
+// Server accepts MRD stream connections and serves each one in its own
+// goroutine.
+type Server struct {
+	// NewChain builds the handler chain for a connection once its config
+	// and header have been received.
+	NewChain func(c *Conn) ([]Handler, error)
+	// ErrorLog receives connection errors. If nil, the log package's
+	// standard logger is used.
+	ErrorLog *log.Logger
+
+	mu       sync.Mutex
+	listener net.Listener
+	closed   bool
+	active   map[net.Conn]struct{}
+	conns    sync.WaitGroup
+}
+
+// ListenAndServe listens on the TCP address addr and calls Serve.
+func (s *Server) ListenAndServe(addr string) error {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	return s.Serve(l)
+}
+
+// Serve accepts connections on l until Close is called.
+func (s *Server) Serve(l net.Listener) error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		l.Close()
+		return fmt.Errorf("server closed")
+	}
+	s.listener = l
+	s.mu.Unlock()
+
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			if s.isClosed() {
+				return nil
+			}
+			return err
+		}
+
+		if !s.track(conn) {
+			conn.Close()
+			return nil
+		}
+		go func() {
+			defer s.untrack(conn)
+			// A bug in a handler, or in decoding a malformed stream,
+			// ends only this session.
+			defer func() {
+				if v := recover(); v != nil {
+					s.logf("%s: panic serving connection: %v\n%s", conn.RemoteAddr(), v, debug.Stack())
+				}
+			}()
+			// Sessions cut short by Close are not worth reporting.
+			if err := s.serveConn(conn); err != nil && !s.isClosed() {
+				s.logf("%s: %v", conn.RemoteAddr(), err)
+			}
+		}()
+	}
+}
+
+func (s *Server) isClosed() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.closed
+}
+
+// track registers an accepted connection, unless the server is closed.
+func (s *Server) track(conn net.Conn) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return false
+	}
+	if s.active == nil {
+		s.active = make(map[net.Conn]struct{})
+	}
+	s.active[conn] = struct{}{}
+	s.conns.Add(1)
+	return true
+}
+
+func (s *Server) untrack(conn net.Conn) {
+	s.mu.Lock()
+	delete(s.active, conn)
+	s.mu.Unlock()
+	s.conns.Done()
+}
+
+// Close stops accepting connections, closes active connections without
+// finishing their handler chains, and waits for their goroutines to return.
+func (s *Server) Close() error {
+	s.mu.Lock()
+	s.closed = true
+	l := s.listener
+	for conn := range s.active {
+		conn.Close()
+	}
+	s.mu.Unlock()
+
+	var err error
+	if l != nil {
+		err = l.Close()
+	}
+	s.conns.Wait()
+	return err
+}
+
+func (s *Server) logf(format string, args ...interface{}) {
+	if s.ErrorLog != nil {
+		s.ErrorLog.Printf(format, args...)
+	} else {
+		log.Printf(format, args...)
+	}
+}
+
+func (s *Server) serveConn(conn net.Conn) error {
+	defer conn.Close()
+
+	buf := bufio.NewWriter(conn)
+	c := &Conn{conn: conn, buf: buf, w: ismrmrd.NewStreamWriter(buf)}
+	br := bufio.NewReader(conn)
+
+	err := s.session(c, br)
+	if err != nil {
+		// Let the client know why the session ended.
+		c.w.WriteText(err.Error())
+	}
+	if cerr := c.w.WriteClose(); err == nil {
+		err = cerr
+	}
+	if ferr := buf.Flush(); err == nil {
+		err = ferr
+	}
+	return err
+}
+
+func (s *Server) session(c *Conn, br *bufio.Reader) error {
+	r := ismrmrd.NewStreamReader(br)
+	for {
+		// Send whatever the handlers have produced before waiting for more
+		// input, so that a client waiting on a result does not deadlock.
+		if br.Buffered() == 0 {
+			if err := c.buf.Flush(); err != nil {
+				return err
+			}
+		}
+		msg, err := r.ReadMessage()
+		if err != nil {
+			return err
+		}
+
+		switch msg.ID {
+		case ismrmrd.MRD_MESSAGE_CONFIG_FILE:
+			c.ConfigFile = msg.Text
+		case ismrmrd.MRD_MESSAGE_CONFIG_TEXT:
+			c.ConfigText = msg.Text
+		case ismrmrd.MRD_MESSAGE_HEADER:
+			if c.chain != nil {
+				return fmt.Errorf("received a second header")
+			}
+			if err := s.start(c, msg); err != nil {
+				return err
+			}
+		case ismrmrd.MRD_MESSAGE_TEXT:
+		case ismrmrd.MRD_MESSAGE_CLOSE:
+			return c.finish()
+		default:
+			if c.chain == nil {
+				return fmt.Errorf("received message %d before the header", msg.ID)
+			}
+			if err := c.emits[0](payload(msg)); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func payload(msg *ismrmrd.Message) interface{} {
+	switch {
+	case msg.Acquisition != nil:
+		return msg.Acquisition
+	case msg.Waveform != nil:
+		return msg.Waveform
+	case msg.Image != nil:
+		return msg.Image
+	case msg.Array != nil:
+		return msg.Array
+	}
+	return nil
+}
+
+// start parses the header and links the handler chain, whose last stage
+// sends items back to the client.
+func (s *Server) start(c *Conn, msg *ismrmrd.Message) error {
+	head, err := msg.Header()
+	if err != nil {
+		return err
+	}
+	c.Header = head
+	c.XMLHeader = msg.Text
+
+	if s.NewChain != nil {
+		if c.chain, err = s.NewChain(c); err != nil {
+			return err
+		}
+	}
+	if c.chain == nil {
+		c.chain = []Handler{}
+	}
+
+	c.emits = make([]Emit, len(c.chain)+1)
+	c.emits[len(c.chain)] = c.send
+	for i := len(c.chain) - 1; i >= 0; i-- {
+		h, next := c.chain[i], c.emits[i+1]
+		c.emits[i] = func(item interface{}) error {
+			return h.Handle(c, item, next)
+		}
+	}
+
+	return nil
+}
+
+// finish finishes each handler in order, so that results flushed by one
+// stage still pass through the stages after it.
+func (c *Conn) finish() error {
+	for i, h := range c.chain {
+		if err := h.Finish(c, c.emits[i+1]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *Conn) send(item interface{}) error {
+	switch v := item.(type) {
+	case *ismrmrd.Image:
+		return c.w.WriteImage(v)
+	case *ismrmrd.NDArray:
+		return c.w.WriteArray(v)
+	case *ismrmrd.Acquisition:
+		return c.w.WriteAcquisition(v)
+	case *ismrmrd.Waveform:
+		return c.w.WriteWaveform(v)
+	case string:
+		return c.w.WriteText(v)
+	}
+	return fmt.Errorf("cannot send %T to the client", item)
+}
@@ -0,0 +1,280 @@
+package server
+
+import (
+	"bytes"
+	"log"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/naegelejd/go-ismrmrd"
+)
+
+// averager buffers acquisitions and emits their count as an array when the
+// stream ends.
+type averager struct {
+	n int
+}
+
+func (a *averager) Handle(c *Conn, item interface{}, next Emit) error {
+	if _, ok := item.(*ismrmrd.Acquisition); ok {
+		a.n++
+		return nil
+	}
+	return next(item)
+}
+
+func (a *averager) Finish(c *Conn, next Emit) error {
+	arr, err := ismrmrd.NewNDArray(ismrmrd.ISMRMRD_INT, 1)
+	if err != nil {
+		return err
+	}
+	arr.Data.([]int32)[0] = int32(a.n)
+	return next(arr)
+}
+
+func TestServer(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var config string
+	s := &Server{
+		NewChain: func(c *Conn) ([]Handler, error) {
+			config = c.ConfigFile
+			waveforms := HandlerFunc(func(c *Conn, item interface{}, next Emit) error {
+				if wav, ok := item.(*ismrmrd.Waveform); ok {
+					img, err := ismrmrd.NewImage(ismrmrd.ISMRMRD_USHORT, 1, 1, 1, 1)
+					if err != nil {
+						return err
+					}
+					img.Head.ImageIndex = wav.Head.WaveformID
+					return next(img)
+				}
+				return next(item)
+			})
+			return []Handler{waveforms, &averager{}}, nil
+		},
+	}
+	served := make(chan error, 1)
+	go func() {
+		served <- s.Serve(l)
+	}()
+
+	conn, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	w := ismrmrd.NewStreamWriter(conn)
+	steps := []error{
+		w.WriteConfigFile("recon.xml"),
+		w.WriteXMLHeader(`<ismrmrdHeader xmlns="http://www.ismrm.org/ISMRMRD"></ismrmrdHeader>`),
+		w.WriteAcquisition(&ismrmrd.Acquisition{}),
+		w.WriteWaveform(&ismrmrd.Waveform{Head: ismrmrd.WaveformHeader{WaveformID: 3}}),
+		w.WriteAcquisition(&ismrmrd.Acquisition{}),
+		w.WriteClose(),
+	}
+	for i, err := range steps {
+		if err != nil {
+			t.Fatalf("write %d: %v", i, err)
+		}
+	}
+
+	r := ismrmrd.NewStreamReader(conn)
+	var ids []uint16
+	var msgs []*ismrmrd.Message
+	for {
+		msg, err := r.ReadMessage()
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, msg.ID)
+		msgs = append(msgs, msg)
+		if msg.ID == ismrmrd.MRD_MESSAGE_CLOSE {
+			break
+		}
+	}
+
+	if len(ids) != 3 || ids[0] != ismrmrd.MRD_MESSAGE_ISMRMRD_IMAGE ||
+		ids[1] != ismrmrd.MRD_MESSAGE_ISMRMRD_NDARRAY {
+		t.Fatalf("unexpected messages from server %v", ids)
+	}
+	if msgs[0].Image.Head.ImageIndex != 3 {
+		t.Fatalf("image index %d, expected 3", msgs[0].Image.Head.ImageIndex)
+	}
+	if n := msgs[1].Array.Data.([]int32)[0]; n != 2 {
+		t.Fatalf("handler saw %d acquisitions, expected 2", n)
+	}
+	if config != "recon.xml" {
+		t.Fatalf("config %q, expected recon.xml", config)
+	}
+
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-served; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestServerDataBeforeHeader(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &Server{}
+	go s.Serve(l)
+	defer s.Close()
+
+	conn, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	if err := ismrmrd.NewStreamWriter(conn).WriteAcquisition(&ismrmrd.Acquisition{}); err != nil {
+		t.Fatal(err)
+	}
+
+	r := ismrmrd.NewStreamReader(conn)
+	msg, err := r.ReadMessage()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg.ID != ismrmrd.MRD_MESSAGE_TEXT {
+		t.Fatalf("expected an error text message, read %d", msg.ID)
+	}
+	if msg, err = r.ReadMessage(); err != nil || msg.ID != ismrmrd.MRD_MESSAGE_CLOSE {
+		t.Fatalf("expected a close message, read %v (%v)", msg, err)
+	}
+}
+
+// startServer serves s on a loopback listener and returns its address.
+func startServer(t *testing.T, s *Server) string {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go s.Serve(l)
+	return l.Addr().String()
+}
+
+const emptyHeader = `<ismrmrdHeader xmlns="http://www.ismrm.org/ISMRMRD"></ismrmrdHeader>`
+
+func TestServerIntermediateResults(t *testing.T) {
+	s := &Server{
+		NewChain: func(c *Conn) ([]Handler, error) {
+			return []Handler{HandlerFunc(func(c *Conn, item interface{}, next Emit) error {
+				return next("got it")
+			})}, nil
+		},
+	}
+	defer s.Close()
+
+	conn, err := net.Dial("tcp", startServer(t, s))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+	w := ismrmrd.NewStreamWriter(conn)
+	if err := w.WriteXMLHeader(emptyHeader); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.WriteAcquisition(&ismrmrd.Acquisition{}); err != nil {
+		t.Fatal(err)
+	}
+
+	// The result must arrive before the client closes the stream.
+	msg, err := ismrmrd.NewStreamReader(conn).ReadMessage()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg.ID != ismrmrd.MRD_MESSAGE_TEXT || msg.Text != "got it" {
+		t.Fatalf("unexpected message %+v", msg)
+	}
+}
+
+func TestServerPanic(t *testing.T) {
+	var logged bytes.Buffer
+	s := &Server{
+		NewChain: func(c *Conn) ([]Handler, error) {
+			return []Handler{HandlerFunc(func(c *Conn, item interface{}, next Emit) error {
+				panic("broken handler")
+			})}, nil
+		},
+		ErrorLog: log.New(&logged, "", 0),
+	}
+	addr := startServer(t, s)
+
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+	w := ismrmrd.NewStreamWriter(conn)
+	if err := w.WriteXMLHeader(emptyHeader); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.WriteAcquisition(&ismrmrd.Acquisition{}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ismrmrd.NewStreamReader(conn).ReadMessage(); err == nil {
+		t.Fatal("expected the panicking session to be closed")
+	}
+
+	// The server keeps serving other clients.
+	other, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer other.Close()
+	other.SetDeadline(time.Now().Add(5 * time.Second))
+	if err := ismrmrd.NewStreamWriter(other).WriteClose(); err != nil {
+		t.Fatal(err)
+	}
+	if msg, err := ismrmrd.NewStreamReader(other).ReadMessage(); err != nil || msg.ID != ismrmrd.MRD_MESSAGE_CLOSE {
+		t.Fatalf("expected a close message, read %v (%v)", msg, err)
+	}
+
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(logged.String(), "broken handler") {
+		t.Fatalf("panic was not logged (%q)", logged.String())
+	}
+}
+
+func TestServerCloseIdleConnection(t *testing.T) {
+	s := &Server{}
+	conn, err := net.Dial("tcp", startServer(t, s))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if err := ismrmrd.NewStreamWriter(conn).WriteXMLHeader(emptyHeader); err != nil {
+		t.Fatal(err)
+	}
+	// Wait for the session to start, then leave it hanging.
+	time.Sleep(50 * time.Millisecond)
+
+	closed := make(chan error, 1)
+	go func() {
+		closed <- s.Close()
+	}()
+	select {
+	case err := <-closed:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Close blocked on a client that never closed its stream")
+	}
+}
@@ -0,0 +1,107 @@
+// Command stream-to-hdf5 reads an MRD stream from stdin and stores it in a
+// new ISMRMRD dataset.
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+
+	"github.com/naegelejd/go-ismrmrd"
+)
+
+func main() {
+	filename := flag.String("file", "", "output HDF5 file")
+	groupname := flag.String("group", "dataset", "dataset group within the file")
+	arrPath := flag.String("arrays", "arrays", "dataset for arrays, relative to the dataset; arrays of other shapes or types go to <arrays>_1, <arrays>_2, ...")
+	flag.Parse()
+
+	if *filename == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	dset, err := ismrmrd.OpenDataset(*filename, *groupname,
+		ismrmrd.ReadWrite|ismrmrd.CreateIfMissing|ismrmrd.FailIfExists)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	err = convert(ismrmrd.NewStreamReader(bufio.NewReader(os.Stdin)), dset, *arrPath)
+	if cerr := dset.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+// acquisitionBatchSize is the number of acquisitions buffered before they
+// are appended to the dataset.
+const acquisitionBatchSize = 1024
+
+// convert stores every message read from r until the close message, or the
+// end of the stream if the sender never closes it. Images are stored per
+// series in "image_<series index>", as the C tools do, and arrays per
+// distinct data type and shape, starting with arrPath.
+func convert(r *ismrmrd.StreamReader, dset *ismrmrd.Dataset, arrPath string) error {
+	var acqs []ismrmrd.Acquisition
+	flush := func() error {
+		if len(acqs) == 0 {
+			return nil
+		}
+		err := dset.AppendAcquisitions(acqs)
+		acqs = acqs[:0]
+		return err
+	}
+	arrPaths := make(map[string]string)
+
+	for {
+		msg, err := r.ReadMessage()
+		if err == io.EOF {
+			return flush()
+		} else if err != nil {
+			return err
+		}
+
+		switch msg.ID {
+		case ismrmrd.MRD_MESSAGE_HEADER:
+			err = dset.WriteXMLHeader(msg.Text)
+		case ismrmrd.MRD_MESSAGE_ISMRMRD_ACQUISITION:
+			acqs = append(acqs, *msg.Acquisition)
+			if len(acqs) == acquisitionBatchSize {
+				err = flush()
+			}
+		case ismrmrd.MRD_MESSAGE_ISMRMRD_WAVEFORM:
+			err = dset.AppendWaveform(msg.Waveform)
+		case ismrmrd.MRD_MESSAGE_ISMRMRD_IMAGE:
+			err = dset.AppendImage(ismrmrd.ImageSeriesPath(msg.Image), msg.Image)
+		case ismrmrd.MRD_MESSAGE_ISMRMRD_NDARRAY:
+			err = dset.AppendArray(arrayPath(arrPaths, arrPath, msg.Array), msg.Array)
+		case ismrmrd.MRD_MESSAGE_CLOSE:
+			return flush()
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// arrayPath returns the dataset for arr. Every array in a dataset must have
+// the same data type and dimensions, so each new combination is given its
+// own dataset, recorded in paths.
+func arrayPath(paths map[string]string, arrPath string, arr *ismrmrd.NDArray) string {
+	key := fmt.Sprintf("%d %v", arr.DataType, arr.Dims)
+	if path, ok := paths[key]; ok {
+		return path
+	}
+	path := arrPath
+	if n := len(paths); n > 0 {
+		path = fmt.Sprintf("%s_%d", arrPath, n)
+	}
+	paths[key] = path
+	return path
+}
@@ -0,0 +1,204 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/naegelejd/go-ismrmrd"
+)
+
+const (
+	input  = "input.h5"
+	output = "output.h5"
+)
+
+const emptyHeader = `<ismrmrdHeader xmlns="http://www.ismrm.org/ISMRMRD"></ismrmrdHeader>`
+
+// build compiles the command in dir into bin and returns its path.
+func build(t *testing.T, bin, dir string) string {
+	path := filepath.Join(bin, filepath.Base(dir))
+	if out, err := exec.Command("go", "build", "-o", path, dir).CombinedOutput(); err != nil {
+		t.Fatalf("building %s: %v\n%s", dir, err, out)
+	}
+	return path
+}
+
+func createInput(t *testing.T) {
+	dset, err := ismrmrd.Create(input, "dataset")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dset.Close()
+
+	if err := dset.WriteXMLHeader(emptyHeader); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		acq := &ismrmrd.Acquisition{}
+		acq.Head.ScanCounter = uint32(i)
+		acq.Head.NumberOfSamples = 2
+		acq.Head.ActiveChannels = 1
+		acq.Data = []complex64{complex(float32(i), 1), complex(2, -float32(i))}
+		if err := dset.AppendAcquisition(acq); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i, series := range []uint16{0, 0, 1} {
+		img, err := ismrmrd.NewImage(ismrmrd.ISMRMRD_FLOAT, 2, 1, 1, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		img.Head.ImageSeriesIndex = series
+		img.Head.ImageIndex = uint16(i)
+		img.Data = []float32{float32(i), -float32(i)}
+		if err := dset.AppendImage(ismrmrd.ImageSeriesPath(img), img); err != nil {
+			t.Fatal(err)
+		}
+	}
+	arr, err := ismrmrd.NewNDArray(ismrmrd.ISMRMRD_INT, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	arr.Data = []int32{7, 8}
+	if err := dset.AppendArray("arrays", arr); err != nil {
+		t.Fatal(err)
+	}
+	arr, err = ismrmrd.NewNDArray(ismrmrd.ISMRMRD_CXFLOAT, 2, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := dset.AppendArray("arrays_1", arr); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("go tool not found")
+	}
+	bin, err := ioutil.TempDir("", "stream-to-hdf5")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(bin)
+	toStream := build(t, bin, "../hdf5-to-stream")
+	fromStream := build(t, bin, ".")
+
+	createInput(t)
+	defer os.Remove(input)
+	defer os.Remove(output)
+
+	send := exec.Command(toStream, "-file", input)
+	recv := exec.Command(fromStream, "-file", output)
+	if recv.Stdin, err = send.StdoutPipe(); err != nil {
+		t.Fatal(err)
+	}
+	send.Stderr = os.Stderr
+	recv.Stderr = os.Stderr
+	if err := recv.Start(); err != nil {
+		t.Fatal(err)
+	}
+	if err := send.Run(); err != nil {
+		t.Fatal(err)
+	}
+	if err := recv.Wait(); err != nil {
+		t.Fatal(err)
+	}
+
+	in, err := ismrmrd.OpenDataset(input, "dataset", ismrmrd.ReadOnly)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer in.Close()
+	out, err := ismrmrd.OpenDataset(output, "dataset", ismrmrd.ReadOnly)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer out.Close()
+
+	want, err := in.Contents()
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := out.Contents()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("contents differ after a round trip: %+v, expected %+v", got, want)
+	}
+	if len(got.Images) != 2 || got.Images[1].Name != "image_1" || got.Images[1].Count != 1 {
+		t.Fatalf("images were not stored per series: %+v", got.Images)
+	}
+
+	if xml, err := out.ReadXMLHeader(); err != nil || xml != emptyHeader {
+		t.Fatalf("read header %q (%v)", xml, err)
+	}
+	acq, err := out.ReadAcquisition(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if acq.Head.ScanCounter != 2 || !reflect.DeepEqual(acq.Data, []complex64{2 + 1i, 2 - 2i}) {
+		t.Fatalf("acquisition 2 changed: %+v", acq)
+	}
+	img, err := out.ReadImage("image_0", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if img.Head.ImageIndex != 1 || !reflect.DeepEqual(img.Data, []float32{1, -1}) {
+		t.Fatalf("image 1 changed: %+v", img)
+	}
+	arr, err := out.ReadArray("arrays", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(arr.Data, []int32{7, 8}) {
+		t.Fatalf("array changed: %v", arr.Data)
+	}
+}
+
+func TestConvertArrays(t *testing.T) {
+	var stream bytes.Buffer
+	w := ismrmrd.NewStreamWriter(&stream)
+	for _, dims := range [][]uint{{2}, {3, 2}, {2}} {
+		arr, err := ismrmrd.NewNDArray(ismrmrd.ISMRMRD_FLOAT, dims...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := w.WriteArray(arr); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i := 0; i < acquisitionBatchSize+1; i++ {
+		acq := &ismrmrd.Acquisition{}
+		acq.Head.ScanCounter = uint32(i)
+		if err := w.WriteAcquisition(acq); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.WriteClose(); err != nil {
+		t.Fatal(err)
+	}
+
+	dset, err := ismrmrd.Create(output, "dataset")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(output)
+	defer dset.Close()
+
+	if err := convert(ismrmrd.NewStreamReader(&stream), dset, "arrays"); err != nil {
+		t.Fatal(err)
+	}
+	if n0, n1 := dset.NumberOfArrays("arrays"), dset.NumberOfArrays("arrays_1"); n0 != 2 || n1 != 1 {
+		t.Fatalf("expected 2 and 1 arrays per shape, stored %d and %d", n0, n1)
+	}
+	if n := dset.NumberOfAcquisitions(); n != acquisitionBatchSize+1 {
+		t.Fatalf("expected %d acquisitions, stored %d", acquisitionBatchSize+1, n)
+	}
+}
@@ -0,0 +1,442 @@
+package ismrmrd
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math/bits"
+)
+
+// MRD stream message identifiers
+const (
+	MRD_MESSAGE_CONFIG_FILE         = 1
+	MRD_MESSAGE_CONFIG_TEXT         = 2
+	MRD_MESSAGE_HEADER              = 3
+	MRD_MESSAGE_CLOSE               = 4
+	MRD_MESSAGE_TEXT                = 5
+	MRD_MESSAGE_ISMRMRD_ACQUISITION = 1008
+	MRD_MESSAGE_ISMRMRD_IMAGE       = 1022
+	MRD_MESSAGE_ISMRMRD_WAVEFORM    = 1026
+	MRD_MESSAGE_ISMRMRD_NDARRAY     = 1030
+
+	// Length of the fixed-size config file name payload
+	MRD_CONFIG_FILE_LENGTH = 1024
+)
+
+// DefaultMaxMessageSize is the largest variable-length payload a
+// StreamReader accepts unless told otherwise.
+const DefaultMaxMessageSize = 1 << 30
+
+// Every multi-byte value in an MRD stream is little-endian.
+var byteOrder = binary.LittleEndian
+
+// Message is a single message read from an MRD stream. ID identifies the
+// message, and at most one of the remaining fields is set: Text holds the
+// payload of config file, config text, header and text messages.
+type Message struct {
+	ID          uint16
+	Text        string
+	Acquisition *Acquisition
+	Image       *Image
+	Waveform    *Waveform
+	Array       *NDArray
+}
+
+// Header parses the payload of an MRD_MESSAGE_HEADER message.
+func (m *Message) Header() (*IsmrmrdHeader, error) {
+	if m.ID != MRD_MESSAGE_HEADER {
+		return nil, fmt.Errorf("message %d is not a header", m.ID)
+	}
+	return Deserialize([]byte(m.Text))
+}
+
+// wireWaveformHeader is the C layout of WaveformHeader, which, unlike the
+// acquisition and image headers, is not packed.
+type wireWaveformHeader struct {
+	Version         uint16
+	_               [6]byte
+	Flags           uint64
+	MeasurementUID  uint32
+	ScanCounter     uint32
+	TimeStamp       uint32
+	NumberOfSamples uint16
+	Channels        uint16
+	SampleTimeUs    float32
+	WaveformID      uint16
+	_               [2]byte
+}
+
+// StreamWriter writes messages in the MRD streaming protocol.
+type StreamWriter struct {
+	w io.Writer
+}
+
+func NewStreamWriter(w io.Writer) *StreamWriter {
+	return &StreamWriter{w}
+}
+
+func (s *StreamWriter) write(data ...interface{}) error {
+	for _, v := range data {
+		if err := binary.Write(s.w, byteOrder, v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *StreamWriter) writeString(id uint16, str string) error {
+	return s.write(id, uint32(len(str)), []byte(str))
+}
+
+// WriteConfigFile asks the receiver to load the named configuration.
+func (s *StreamWriter) WriteConfigFile(name string) error {
+	if len(name) >= MRD_CONFIG_FILE_LENGTH {
+		return fmt.Errorf("config file name is longer than %d bytes", MRD_CONFIG_FILE_LENGTH-1)
+	}
+	var buf [MRD_CONFIG_FILE_LENGTH]byte
+	copy(buf[:], name)
+	return s.write(uint16(MRD_MESSAGE_CONFIG_FILE), buf[:])
+}
+
+// WriteConfigText sends a complete configuration document.
+func (s *StreamWriter) WriteConfigText(config string) error {
+	return s.writeString(MRD_MESSAGE_CONFIG_TEXT, config)
+}
+
+// WriteXMLHeader sends a raw XML header.
+func (s *StreamWriter) WriteXMLHeader(xml string) error {
+	return s.writeString(MRD_MESSAGE_HEADER, xml)
+}
+
+// WriteHeader serializes and sends head.
+func (s *StreamWriter) WriteHeader(head *IsmrmrdHeader) error {
+	b, err := Serialize(head)
+	if err != nil {
+		return err
+	}
+	return s.WriteXMLHeader(string(b))
+}
+
+// WriteText sends a free-form text message.
+func (s *StreamWriter) WriteText(text string) error {
+	return s.writeString(MRD_MESSAGE_TEXT, text)
+}
+
+// WriteClose signals the end of the stream.
+func (s *StreamWriter) WriteClose() error {
+	return s.write(uint16(MRD_MESSAGE_CLOSE))
+}
+
+func (s *StreamWriter) WriteAcquisition(acq *Acquisition) error {
+	h := &acq.Head
+	if n := int(h.NumberOfSamples) * int(h.TrajectoryDimensions); len(acq.Traj) != n {
+		return fmt.Errorf("acquisition has %d trajectory points, header describes %d", len(acq.Traj), n)
+	}
+	if n := int(h.NumberOfSamples) * int(h.ActiveChannels); len(acq.Data) != n {
+		return fmt.Errorf("acquisition has %d samples, header describes %d", len(acq.Data), n)
+	}
+	head, err := h.MarshalBinary()
+	if err != nil {
+		return err
+	}
+	return s.write(uint16(MRD_MESSAGE_ISMRMRD_ACQUISITION), head, acq.Traj, acq.Data)
+}
+
+func (s *StreamWriter) WriteImage(img *Image) error {
+	dataType, n, err := dataTypeOf(img.Data)
+	if err != nil {
+		return err
+	}
+	if dataType != img.Head.DataType {
+		return fmt.Errorf("image data is %T but header data type is %d", img.Data, img.Head.DataType)
+	}
+	if n != img.NumberOfElements() {
+		return fmt.Errorf("image has %d pixels, header describes %d", n, img.NumberOfElements())
+	}
+
+	h := img.Head
+	h.AttributeStringLen = uint32(len(img.Attributes))
+	head, err := h.MarshalBinary()
+	if err != nil {
+		return err
+	}
+	return s.write(uint16(MRD_MESSAGE_ISMRMRD_IMAGE), head,
+		uint64(len(img.Attributes)), []byte(img.Attributes), img.Data)
+}
+
+func (s *StreamWriter) WriteWaveform(wav *Waveform) error {
+	h := &wav.Head
+	if n := int(h.NumberOfSamples) * int(h.Channels); len(wav.Data) != n {
+		return fmt.Errorf("waveform has %d samples, header describes %d", len(wav.Data), n)
+	}
+	head := wireWaveformHeader{
+		Version:         h.Version,
+		Flags:           h.Flags,
+		MeasurementUID:  h.MeasurementUID,
+		ScanCounter:     h.ScanCounter,
+		TimeStamp:       h.TimeStamp,
+		NumberOfSamples: h.NumberOfSamples,
+		Channels:        h.Channels,
+		SampleTimeUs:    h.SampleTimeUs,
+		WaveformID:      h.WaveformID,
+	}
+	return s.write(uint16(MRD_MESSAGE_ISMRMRD_WAVEFORM), &head, wav.Data)
+}
+
+func (s *StreamWriter) WriteArray(arr *NDArray) error {
+	dataType, n, err := dataTypeOf(arr.Data)
+	if err != nil {
+		return err
+	}
+	if dataType != arr.DataType {
+		return fmt.Errorf("array data is %T but data type is %d", arr.Data, arr.DataType)
+	}
+	if len(arr.Dims) == 0 || len(arr.Dims) > ISMRMRD_NDARRAY_MAXDIM {
+		return fmt.Errorf("invalid number of dimensions %d", len(arr.Dims))
+	}
+	if n != arr.NumberOfElements() {
+		return fmt.Errorf("array has %d elements, dimensions describe %d", n, arr.NumberOfElements())
+	}
+
+	dims := make([]uint64, len(arr.Dims))
+	for i, d := range arr.Dims {
+		dims[i] = uint64(d)
+	}
+	return s.write(uint16(MRD_MESSAGE_ISMRMRD_NDARRAY), arr.Version, arr.DataType,
+		uint16(len(dims)), dims, arr.Data)
+}
+
+// StreamReader reads messages in the MRD streaming protocol.
+type StreamReader struct {
+	r io.Reader
+
+	// MaxMessageSize limits the size in bytes of the strings, attributes
+	// and data of a single message, so that a corrupt or hostile length
+	// cannot make the reader allocate without bound.
+	MaxMessageSize uint64
+}
+
+func NewStreamReader(r io.Reader) *StreamReader {
+	return &StreamReader{r: r, MaxMessageSize: DefaultMaxMessageSize}
+}
+
+// payloadSize returns size times the product of counts, and false if that
+// overflows.
+func payloadSize(size uint64, counts ...uint64) (uint64, bool) {
+	for _, c := range counts {
+		hi, lo := bits.Mul64(size, c)
+		if hi != 0 {
+			return 0, false
+		}
+		size = lo
+	}
+	return size, true
+}
+
+// checkSize returns an error if a payload of n bytes is larger than
+// MaxMessageSize or, when the reader knows it, than the rest of the stream.
+func (s *StreamReader) checkSize(what string, n uint64, ok bool) error {
+	if !ok || n > s.MaxMessageSize {
+		return fmt.Errorf("%s exceeds the maximum message size of %d bytes", what, s.MaxMessageSize)
+	}
+	if l, ok := s.r.(interface{ Len() int }); ok && n > uint64(l.Len()) {
+		return fmt.Errorf("%s of %d bytes exceeds the %d bytes left in the stream", what, n, l.Len())
+	}
+	return nil
+}
+
+func (s *StreamReader) read(data ...interface{}) error {
+	for _, v := range data {
+		if err := binary.Read(s.r, byteOrder, v); err != nil {
+			if err == io.EOF {
+				return io.ErrUnexpectedEOF
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *StreamReader) readString() (string, error) {
+	var n uint32
+	if err := s.read(&n); err != nil {
+		return "", err
+	}
+	if err := s.checkSize("string", uint64(n), true); err != nil {
+		return "", err
+	}
+	buf := make([]byte, n)
+	if _, err := io.ReadFull(s.r, buf); err != nil {
+		return "", err
+	}
+	// Some senders include the C string terminator.
+	return string(bytes.TrimRight(buf, "\x00")), nil
+}
+
+// ReadMessage reads the next message from the stream. It returns io.EOF only
+// if the stream ends cleanly between messages.
+func (s *StreamReader) ReadMessage() (*Message, error) {
+	msg := &Message{}
+	if err := binary.Read(s.r, byteOrder, &msg.ID); err != nil {
+		return nil, err
+	}
+
+	var err error
+	switch msg.ID {
+	case MRD_MESSAGE_CONFIG_FILE:
+		var buf [MRD_CONFIG_FILE_LENGTH]byte
+		if err = s.read(buf[:]); err == nil {
+			if i := bytes.IndexByte(buf[:], 0); i >= 0 {
+				msg.Text = string(buf[:i])
+			} else {
+				msg.Text = string(buf[:])
+			}
+		}
+	case MRD_MESSAGE_CONFIG_TEXT, MRD_MESSAGE_HEADER, MRD_MESSAGE_TEXT:
+		msg.Text, err = s.readString()
+	case MRD_MESSAGE_CLOSE:
+	case MRD_MESSAGE_ISMRMRD_ACQUISITION:
+		msg.Acquisition, err = s.readAcquisition()
+	case MRD_MESSAGE_ISMRMRD_IMAGE:
+		msg.Image, err = s.readImage()
+	case MRD_MESSAGE_ISMRMRD_WAVEFORM:
+		msg.Waveform, err = s.readWaveform()
+	case MRD_MESSAGE_ISMRMRD_NDARRAY:
+		msg.Array, err = s.readArray()
+	default:
+		err = fmt.Errorf("unknown message id %d", msg.ID)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return msg, nil
+}
+
+func (s *StreamReader) readAcquisition() (*Acquisition, error) {
+	acq := &Acquisition{}
+	head := make([]byte, ACQUISITION_HEADER_SIZE)
+	if err := s.read(head); err != nil {
+		return nil, err
+	}
+	if err := acq.Head.UnmarshalBinary(head); err != nil {
+		return nil, err
+	}
+	h := &acq.Head
+	size, ok := payloadSize(uint64(h.NumberOfSamples),
+		4*uint64(h.TrajectoryDimensions)+8*uint64(h.ActiveChannels))
+	if err := s.checkSize("acquisition data", size, ok); err != nil {
+		return nil, err
+	}
+	acq.Traj = make([]float32, int(h.NumberOfSamples)*int(h.TrajectoryDimensions))
+	acq.Data = make([]complex64, int(h.NumberOfSamples)*int(h.ActiveChannels))
+	if err := s.read(acq.Traj, acq.Data); err != nil {
+		return nil, err
+	}
+	return acq, nil
+}
+
+func (s *StreamReader) readImage() (*Image, error) {
+	img := &Image{}
+	head := make([]byte, IMAGE_HEADER_SIZE)
+	var attrLen uint64
+	if err := s.read(head, &attrLen); err != nil {
+		return nil, err
+	}
+	if err := img.Head.UnmarshalBinary(head); err != nil {
+		return nil, err
+	}
+	h := &img.Head
+	elemSize, err := dataTypeSize(h.DataType)
+	if err != nil {
+		return nil, err
+	}
+	size, ok := payloadSize(uint64(elemSize), uint64(h.MatrixSize[0]), uint64(h.MatrixSize[1]),
+		uint64(h.MatrixSize[2]), uint64(h.Channels))
+	if err := s.checkSize("image attributes and data", attrLen+size, ok && attrLen+size >= size); err != nil {
+		return nil, err
+	}
+
+	attrs := make([]byte, attrLen)
+	if err := s.read(attrs); err != nil {
+		return nil, err
+	}
+	img.Attributes = string(attrs)
+
+	data, err := makeData(img.Head.DataType, img.NumberOfElements())
+	if err != nil {
+		return nil, err
+	}
+	if err := s.read(data); err != nil {
+		return nil, err
+	}
+	img.Data = data
+
+	return img, nil
+}
+
+func (s *StreamReader) readWaveform() (*Waveform, error) {
+	var head wireWaveformHeader
+	if err := s.read(&head); err != nil {
+		return nil, err
+	}
+	wav := &Waveform{Head: WaveformHeader{
+		Version:         head.Version,
+		Flags:           head.Flags,
+		MeasurementUID:  head.MeasurementUID,
+		ScanCounter:     head.ScanCounter,
+		TimeStamp:       head.TimeStamp,
+		NumberOfSamples: head.NumberOfSamples,
+		Channels:        head.Channels,
+		SampleTimeUs:    head.SampleTimeUs,
+		WaveformID:      head.WaveformID,
+	}}
+	size, _ := payloadSize(4, uint64(head.NumberOfSamples), uint64(head.Channels))
+	if err := s.checkSize("waveform data", size, true); err != nil {
+		return nil, err
+	}
+	wav.Data = make([]uint32, int(head.NumberOfSamples)*int(head.Channels))
+	if err := s.read(wav.Data); err != nil {
+		return nil, err
+	}
+	return wav, nil
+}
+
+func (s *StreamReader) readArray() (*NDArray, error) {
+	var version, dataType, ndim uint16
+	if err := s.read(&version, &dataType, &ndim); err != nil {
+		return nil, err
+	}
+	if ndim == 0 || ndim > ISMRMRD_NDARRAY_MAXDIM {
+		return nil, fmt.Errorf("invalid number of dimensions %d", ndim)
+	}
+
+	dims64 := make([]uint64, ndim)
+	if err := s.read(dims64); err != nil {
+		return nil, err
+	}
+	elemSize, err := dataTypeSize(dataType)
+	if err != nil {
+		return nil, err
+	}
+	size, ok := payloadSize(uint64(elemSize), dims64...)
+	if err := s.checkSize("array data", size, ok); err != nil {
+		return nil, err
+	}
+	dims := make([]uint, ndim)
+	for i, d := range dims64 {
+		dims[i] = uint(d)
+	}
+
+	arr, err := NewNDArray(dataType, dims...)
+	if err != nil {
+		return nil, err
+	}
+	arr.Version = version
+	if err := s.read(arr.Data); err != nil {
+		return nil, err
+	}
+
+	return arr, nil
+}
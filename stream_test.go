@@ -0,0 +1,209 @@
+package ismrmrd
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"testing"
+)
+
+func TestStreamRoundTrip(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewStreamWriter(&buf)
+
+	acq := &Acquisition{}
+	acq.Head.Version = ISMRMRD_VERSION_MAJOR
+	acq.Head.ScanCounter = 3
+	acq.Head.NumberOfSamples = 2
+	acq.Head.ActiveChannels = 2
+	acq.Head.TrajectoryDimensions = 1
+	acq.Head.Idx.Slice = 4
+	acq.Traj = []float32{-0.5, 0.5}
+	acq.Data = []complex64{1, 2i, 3, 4i}
+
+	img, err := NewImage(ISMRMRD_SHORT, 2, 2, 1, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	img.Head.ImageSeriesIndex = 5
+	img.Attributes = "<ismrmrdMeta/>"
+	copy(img.Data.([]int16), []int16{1, -2, 3, -4})
+
+	wav := &Waveform{}
+	wav.Head.NumberOfSamples = 2
+	wav.Head.Channels = 1
+	wav.Head.WaveformID = 2
+	wav.Data = []uint32{7, 8}
+
+	arr, err := NewNDArray(ISMRMRD_DOUBLE, 3, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	arr.Data.([]float64)[5] = 1.5
+
+	steps := []error{
+		w.WriteConfigFile("default.xml"),
+		w.WriteConfigText("<config/>"),
+		w.WriteHeader(testHeader),
+		w.WriteText("hello"),
+		w.WriteAcquisition(acq),
+		w.WriteImage(img),
+		w.WriteWaveform(wav),
+		w.WriteArray(arr),
+		w.WriteClose(),
+	}
+	for i, err := range steps {
+		if err != nil {
+			t.Fatalf("write %d: %v", i, err)
+		}
+	}
+
+	r := NewStreamReader(&buf)
+	read := func(id uint16) *Message {
+		msg, err := r.ReadMessage()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if msg.ID != id {
+			t.Fatalf("expected message %d, read %d", id, msg.ID)
+		}
+		return msg
+	}
+
+	if msg := read(MRD_MESSAGE_CONFIG_FILE); msg.Text != "default.xml" {
+		t.Fatalf("config file %q does not match what was written", msg.Text)
+	}
+	if msg := read(MRD_MESSAGE_CONFIG_TEXT); msg.Text != "<config/>" {
+		t.Fatalf("config text %q does not match what was written", msg.Text)
+	}
+	head, err := read(MRD_MESSAGE_HEADER).Header()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !equal(head, testHeader) {
+		t.Fatal("header does not match what was written")
+	}
+	if msg := read(MRD_MESSAGE_TEXT); msg.Text != "hello" {
+		t.Fatalf("text %q does not match what was written", msg.Text)
+	}
+
+	gotAcq := read(MRD_MESSAGE_ISMRMRD_ACQUISITION).Acquisition
+	if gotAcq.Head != acq.Head || gotAcq.Traj[1] != acq.Traj[1] || gotAcq.Data[3] != acq.Data[3] {
+		t.Fatalf("acquisition does not match what was written (%+v)", gotAcq)
+	}
+
+	gotImg := read(MRD_MESSAGE_ISMRMRD_IMAGE).Image
+	if gotImg.Head.ImageSeriesIndex != 5 || gotImg.Attributes != img.Attributes ||
+		gotImg.Head.AttributeStringLen != uint32(len(img.Attributes)) {
+		t.Fatalf("image does not match what was written (%+v)", gotImg)
+	}
+	if gotImg.Data.([]int16)[3] != -4 {
+		t.Fatalf("image data does not match what was written (%v)", gotImg.Data)
+	}
+
+	gotWav := read(MRD_MESSAGE_ISMRMRD_WAVEFORM).Waveform
+	if gotWav.Head != wav.Head || gotWav.Data[1] != 8 {
+		t.Fatalf("waveform does not match what was written (%+v)", gotWav)
+	}
+
+	gotArr := read(MRD_MESSAGE_ISMRMRD_NDARRAY).Array
+	if len(gotArr.Dims) != 2 || gotArr.Dims[0] != 3 || gotArr.Data.([]float64)[5] != 1.5 {
+		t.Fatalf("array does not match what was written (%+v)", gotArr)
+	}
+
+	read(MRD_MESSAGE_CLOSE)
+	if _, err := r.ReadMessage(); err != io.EOF {
+		t.Fatalf("expected io.EOF at end of stream, got %v", err)
+	}
+}
+
+func TestStreamMessageSizes(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewStreamWriter(&buf)
+
+	if err := w.WriteAcquisition(&Acquisition{}); err != nil {
+		t.Fatal(err)
+	}
+	if n := buf.Len(); n != 2+340 {
+		t.Fatalf("empty acquisition message is %d bytes, expected %d", n, 2+340)
+	}
+
+	buf.Reset()
+	if err := w.WriteWaveform(&Waveform{}); err != nil {
+		t.Fatal(err)
+	}
+	if n := buf.Len(); n != 2+40 {
+		t.Fatalf("empty waveform message is %d bytes, expected %d", n, 2+40)
+	}
+
+	acq := &Acquisition{}
+	acq.Head.NumberOfSamples = 4
+	acq.Head.ActiveChannels = 1
+	if err := w.WriteAcquisition(acq); err == nil {
+		t.Fatal("expected error writing an acquisition with missing data")
+	}
+}
+
+func TestStreamUnknownMessage(t *testing.T) {
+	r := NewStreamReader(bytes.NewReader([]byte{0xff, 0xff}))
+	if _, err := r.ReadMessage(); err == nil {
+		t.Fatal("expected error reading an unknown message id")
+	}
+}
+
+func TestStreamOversizedLengths(t *testing.T) {
+	message := func(data ...interface{}) []byte {
+		var buf bytes.Buffer
+		for _, v := range data {
+			if err := binary.Write(&buf, binary.LittleEndian, v); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return buf.Bytes()
+	}
+
+	var acq AcquisitionHeader
+	acq.NumberOfSamples = 0xffff
+	acq.ActiveChannels = 0xffff
+	acqHead, err := acq.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var img ImageHeader
+	img.DataType = ISMRMRD_CXDOUBLE
+	img.MatrixSize = [3]uint16{0xffff, 0xffff, 0xffff}
+	img.Channels = 0xffff
+	imgHead, err := img.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	smallImg := ImageHeader{DataType: ISMRMRD_FLOAT}
+	smallImgHead, err := smallImg.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	streams := map[string][]byte{
+		"string":           message(uint16(MRD_MESSAGE_TEXT), uint32(0xffffffff)),
+		"acquisition":      message(uint16(MRD_MESSAGE_ISMRMRD_ACQUISITION), acqHead),
+		"image attributes": message(uint16(MRD_MESSAGE_ISMRMRD_IMAGE), smallImgHead, uint64(1<<63)),
+		"image data":       message(uint16(MRD_MESSAGE_ISMRMRD_IMAGE), imgHead, uint64(0)),
+		"waveform": message(uint16(MRD_MESSAGE_ISMRMRD_WAVEFORM),
+			wireWaveformHeader{NumberOfSamples: 0xffff, Channels: 0xffff}),
+		"array": message(uint16(MRD_MESSAGE_ISMRMRD_NDARRAY), uint16(1), uint16(ISMRMRD_DOUBLE),
+			uint16(2), []uint64{1 << 40, 1 << 40}),
+		"array overflow": message(uint16(MRD_MESSAGE_ISMRMRD_NDARRAY), uint16(1), uint16(ISMRMRD_DOUBLE),
+			uint16(2), []uint64{1 << 63, 2}),
+	}
+	for name, data := range streams {
+		// The bytes.Reader knows how much is left; hiding it behind
+		// io.MultiReader leaves only MaxMessageSize to catch the length.
+		for _, r := range []io.Reader{bytes.NewReader(data), io.MultiReader(bytes.NewReader(data))} {
+			sr := NewStreamReader(r)
+			sr.MaxMessageSize = 1 << 20
+			if _, err := sr.ReadMessage(); err == nil {
+				t.Fatalf("%s: expected error reading an oversized length", name)
+			}
+		}
+	}
+}
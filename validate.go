@@ -0,0 +1,515 @@
+package ismrmrd
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/xml"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ValidationError locates one problem found while validating a header
+// document. Path addresses the offending element, e.g.
+// "/ismrmrdHeader/encoding[1]/trajectory".
+type ValidationError struct {
+	Path    string
+	Line    int
+	Message string
+}
+
+func (e ValidationError) Error() string {
+	return fmt.Sprintf("line %d: %s: %s", e.Line, e.Path, e.Message)
+}
+
+// The rules below mirror ismrmrd.xsd. A schemaType either has simple
+// content, checked by check, or a sequence of child elements.
+type schemaType struct {
+	check    func(string) error
+	elements []schemaElement
+	// unordered is set for types declared with xs:all.
+	unordered bool
+}
+
+type schemaElement struct {
+	name     string
+	min, max int
+	typ      *schemaType
+}
+
+const unbounded = -1
+
+func optional(name string, typ *schemaType) schemaElement {
+	return schemaElement{name, 0, 1, typ}
+}
+
+func required(name string, typ *schemaType) schemaElement {
+	return schemaElement{name, 1, 1, typ}
+}
+
+func repeated(name string, min int, typ *schemaType) schemaElement {
+	return schemaElement{name, min, unbounded, typ}
+}
+
+func sequence(elements ...schemaElement) *schemaType {
+	return &schemaType{elements: elements}
+}
+
+func all(elements ...schemaElement) *schemaType {
+	return &schemaType{elements: elements, unordered: true}
+}
+
+func simpleType(check func(string) error) *schemaType {
+	return &schemaType{check: check}
+}
+
+func enumeration(values ...string) *schemaType {
+	return simpleType(func(s string) error {
+		s = strings.TrimSpace(s)
+		for _, v := range values {
+			if s == v {
+				return nil
+			}
+		}
+		return fmt.Errorf("must be one of %s", strings.Join(values, ", "))
+	})
+}
+
+func unsignedType(name string, bits int) *schemaType {
+	return simpleType(func(s string) error {
+		if _, err := strconv.ParseUint(strings.TrimSpace(s), 10, bits); err != nil {
+			if ne, ok := err.(*strconv.NumError); ok && ne.Err == strconv.ErrRange {
+				return fmt.Errorf("out of range for %s", name)
+			}
+			return fmt.Errorf("not a valid %s", name)
+		}
+		return nil
+	})
+}
+
+func floatType(name string, bits int) *schemaType {
+	return simpleType(func(s string) error {
+		if _, err := strconv.ParseFloat(strings.TrimSpace(s), bits); err != nil {
+			if ne, ok := err.(*strconv.NumError); ok && ne.Err == strconv.ErrRange {
+				return fmt.Errorf("out of range for %s", name)
+			}
+			return fmt.Errorf("not a valid %s", name)
+		}
+		return nil
+	})
+}
+
+func timeType(name string, parse func(name, value string) (time.Time, error)) *schemaType {
+	return simpleType(func(s string) error {
+		if _, err := parse(name, strings.TrimSpace(s)); err != nil {
+			return fmt.Errorf("not a valid %s", name)
+		}
+		return nil
+	})
+}
+
+var (
+	xsString        = simpleType(func(string) error { return nil })
+	xsUnsignedShort = unsignedType("unsignedShort", 16)
+	xsUnsignedInt   = unsignedType("unsignedInt", 32)
+	xsUnsignedLong  = unsignedType("unsignedLong", 64)
+	xsFloat         = floatType("float", 32)
+	xsDouble        = floatType("double", 64)
+	xsDate          = timeType("date", parseXSDDate)
+	xsTime          = timeType("time", parseXSDTime)
+
+	xsLong = simpleType(func(s string) error {
+		if _, err := strconv.ParseInt(strings.TrimSpace(s), 10, 64); err != nil {
+			if ne, ok := err.(*strconv.NumError); ok && ne.Err == strconv.ErrRange {
+				return fmt.Errorf("out of range for long")
+			}
+			return fmt.Errorf("not a valid long")
+		}
+		return nil
+	})
+
+	xsBase64Binary = simpleType(func(s string) error {
+		s = strings.Join(strings.Fields(s), "")
+		if _, err := base64.StdEncoding.DecodeString(s); err != nil {
+			return fmt.Errorf("not valid base64")
+		}
+		return nil
+	})
+)
+
+var (
+	threeDimensionalFloatType = sequence(
+		required("x", xsFloat),
+		required("y", xsFloat),
+		required("z", xsFloat),
+	)
+
+	userParameterLongType = sequence(
+		required("name", xsString),
+		required("value", xsLong),
+	)
+	userParameterDoubleType = sequence(
+		required("name", xsString),
+		required("value", xsDouble),
+	)
+	userParameterStringType = sequence(
+		required("name", xsString),
+		required("value", xsString),
+	)
+	userParameterBase64Type = sequence(
+		required("name", xsString),
+		required("value", xsBase64Binary),
+	)
+	userParametersType = sequence(
+		repeated("userParameterLong", 0, userParameterLongType),
+		repeated("userParameterDouble", 0, userParameterDoubleType),
+		repeated("userParameterString", 0, userParameterStringType),
+		repeated("userParameterBase64", 0, userParameterBase64Type),
+	)
+
+	subjectInformationType = all(
+		optional("patientName", xsString),
+		optional("patientWeight_kg", xsFloat),
+		optional("patientHeight_m", xsFloat),
+		optional("patientID", xsString),
+		optional("patientBirthdate", xsDate),
+		optional("patientGender", enumeration("M", "F", "O")),
+	)
+
+	studyInformationType = all(
+		optional("studyDate", xsDate),
+		optional("studyTime", xsTime),
+		optional("studyID", xsString),
+		optional("accessionNumber", xsLong),
+		optional("referringPhysicianName", xsString),
+		optional("studyDescription", xsString),
+		optional("studyInstanceUID", xsString),
+		optional("bodyPartExamined", xsString),
+	)
+
+	measurementInformationType = sequence(
+		optional("measurementID", xsString),
+		optional("seriesDate", xsDate),
+		optional("seriesTime", xsTime),
+		required("patientPosition", enumeration(
+			"HFP", "HFS", "HDR", "HDL", "FFP", "FFS", "FDR", "FDL")),
+		optional("relativeTablePosition", threeDimensionalFloatType),
+		optional("initialSeriesNumber", xsLong),
+		optional("protocolName", xsString),
+		optional("sequenceName", xsString),
+		optional("seriesDescription", xsString),
+		repeated("measurementDependency", 0, sequence(
+			required("dependencyType", xsString),
+			required("measurementID", xsString),
+		)),
+		optional("seriesInstanceUIDRoot", xsString),
+		optional("frameOfReferenceUID", xsString),
+		optional("referencedImageSequence", sequence(
+			repeated("referencedSOPInstanceUID", 0, xsString),
+		)),
+	)
+
+	acquisitionSystemInformationType = sequence(
+		optional("systemVendor", xsString),
+		optional("systemModel", xsString),
+		optional("systemFieldStrength_T", xsFloat),
+		optional("relativeReceiverNoiseBandwidth", xsFloat),
+		optional("receiverChannels", xsUnsignedShort),
+		repeated("coilLabel", 0, sequence(
+			required("coilNumber", xsUnsignedShort),
+			required("coilName", xsString),
+		)),
+		optional("institutionName", xsString),
+		optional("stationName", xsString),
+		optional("deviceID", xsString),
+		optional("deviceSerialNumber", xsString),
+	)
+
+	experimentalConditionsType = sequence(
+		required("H1resonanceFrequency_Hz", xsLong),
+	)
+
+	encodingSpaceType = sequence(
+		required("matrixSize", sequence(
+			required("x", xsUnsignedShort),
+			required("y", xsUnsignedShort),
+			required("z", xsUnsignedShort),
+		)),
+		required("fieldOfView_mm", threeDimensionalFloatType),
+	)
+
+	limitType = sequence(
+		required("minimum", xsUnsignedShort),
+		required("maximum", xsUnsignedShort),
+		required("center", xsUnsignedShort),
+	)
+
+	encodingLimitsType = sequence(
+		optional("kspace_encoding_step_0", limitType),
+		optional("kspace_encoding_step_1", limitType),
+		optional("kspace_encoding_step_2", limitType),
+		optional("average", limitType),
+		optional("slice", limitType),
+		optional("contrast", limitType),
+		optional("phase", limitType),
+		optional("repetition", limitType),
+		optional("set", limitType),
+		optional("segment", limitType),
+		optional("user_0", limitType),
+		optional("user_1", limitType),
+		optional("user_2", limitType),
+		optional("user_3", limitType),
+		optional("user_4", limitType),
+		optional("user_5", limitType),
+		optional("user_6", limitType),
+		optional("user_7", limitType),
+	)
+
+	trajectoryDescriptionType = sequence(
+		required("identifier", xsString),
+		repeated("userParameterLong", 0, userParameterLongType),
+		repeated("userParameterDouble", 0, userParameterDoubleType),
+		repeated("userParameterString", 0, userParameterStringType),
+		optional("comment", xsString),
+	)
+
+	parallelImagingType = sequence(
+		required("accelerationFactor", sequence(
+			required("kspace_encoding_step_1", xsUnsignedShort),
+			required("kspace_encoding_step_2", xsUnsignedShort),
+		)),
+		optional("calibrationMode", enumeration(
+			"embedded", "interleaved", "separate", "external", "other")),
+		optional("interleavingDimension", enumeration(
+			"phase", "repetition", "contrast", "average", "other")),
+		optional("multiband", sequence(
+			repeated("spacing", 1, sequence(
+				repeated("dZ", 1, xsFloat),
+			)),
+			required("deltaKz", xsFloat),
+			required("multiband_factor", xsUnsignedInt),
+			required("calibration", enumeration(
+				MultibandCalibrationSeparable2D, MultibandCalibrationFull3D, MultibandCalibrationOther)),
+			required("calibration_encoding", xsUnsignedLong),
+		)),
+	)
+
+	encodingType = sequence(
+		required("encodedSpace", encodingSpaceType),
+		required("reconSpace", encodingSpaceType),
+		required("encodingLimits", encodingLimitsType),
+		required("trajectory", enumeration(
+			"cartesian", "epi", "radial", "goldenangle", "spiral", "other")),
+		optional("trajectoryDescription", trajectoryDescriptionType),
+		optional("parallelImaging", parallelImagingType),
+		optional("echoTrainLength", xsLong),
+	)
+
+	sequenceParametersType = sequence(
+		repeated("TR", 0, xsFloat),
+		repeated("TE", 0, xsFloat),
+		repeated("TI", 0, xsFloat),
+		repeated("flipAngle_deg", 0, xsFloat),
+		optional("sequence_type", xsString),
+		repeated("echo_spacing", 0, xsFloat),
+		optional("diffusionDimension", enumeration(
+			"average", "contrast", "phase", "repetition", "set", "segment",
+			"user_0", "user_1", "user_2", "user_3", "user_4", "user_5", "user_6", "user_7")),
+		repeated("diffusion", 0, sequence(
+			required("gradientDirection", sequence(
+				required("rl", xsFloat),
+				required("ap", xsFloat),
+				required("fh", xsFloat),
+			)),
+			required("bvalue", xsFloat),
+		)),
+		optional("diffusionScheme", xsString),
+	)
+
+	waveformInformationType = sequence(
+		required("waveformName", xsString),
+		required("waveformType", enumeration(
+			WaveformECG, WaveformPulse, WaveformRespiratory, WaveformTrigger,
+			WaveformGradientWaveform, WaveformOther)),
+		optional("userParameters", userParametersType),
+	)
+
+	ismrmrdHeaderType = sequence(
+		optional("version", xsLong),
+		optional("subjectInformation", subjectInformationType),
+		optional("studyInformation", studyInformationType),
+		optional("measurementInformation", measurementInformationType),
+		optional("acquisitionSystemInformation", acquisitionSystemInformationType),
+		required("experimentalConditions", experimentalConditionsType),
+		repeated("encoding", 1, encodingType),
+		optional("sequenceParameters", sequenceParametersType),
+		optional("userParameters", userParametersType),
+		repeated("waveformInformation", 0, waveformInformationType),
+	)
+)
+
+const xsiNamespace = "http://www.w3.org/2001/XMLSchema-instance"
+
+// validationFrame tracks an open element while validating.
+type validationFrame struct {
+	path   string
+	line   int
+	typ    *schemaType
+	counts map[string]int
+	// pos is the index in typ.elements of the last child seen.
+	pos     int
+	text    strings.Builder
+	hasText bool
+}
+
+// Validate checks an XML header document against the rules of the ISMRMRD
+// schema: namespaces, required elements, element order and cardinality,
+// enumerations and the ranges of numeric types. It returns every problem
+// found, or nil if the document is valid.
+func Validate(data []byte) []ValidationError {
+	var errs []ValidationError
+	// Offsets only grow, so lines are counted from the previous offset.
+	lines, counted := 1, int64(0)
+	lineAt := func(offset int64) int {
+		lines += bytes.Count(data[counted:offset], []byte("\n"))
+		counted = offset
+		return lines
+	}
+
+	d := xml.NewDecoder(bytes.NewReader(data))
+	var stack []*validationFrame
+	sawRoot := false
+
+	for {
+		offset := d.InputOffset()
+		tok, err := d.Token()
+		if err != nil {
+			if se, ok := err.(*xml.SyntaxError); ok {
+				errs = append(errs, ValidationError{"", se.Line, se.Msg})
+			} else if !sawRoot {
+				errs = append(errs, ValidationError{"", lineAt(offset), "missing ismrmrdHeader element"})
+			}
+			return errs
+		}
+		line := lineAt(offset)
+
+		switch t := tok.(type) {
+		case xml.StartElement:
+			if len(stack) == 0 {
+				sawRoot = true
+				path := "/" + t.Name.Local
+				if t.Name.Local != "ismrmrdHeader" || t.Name.Space != Namespace {
+					errs = append(errs, ValidationError{path, line,
+						fmt.Sprintf("root element must be ismrmrdHeader in namespace %s", Namespace)})
+					return errs
+				}
+				errs = append(errs, checkAttributes(t, path, line)...)
+				stack = append(stack, &validationFrame{path: path, line: line, typ: ismrmrdHeaderType, counts: map[string]int{}})
+				continue
+			}
+
+			parent := stack[len(stack)-1]
+			path := parent.path + "/" + t.Name.Local
+			if parent.typ.check != nil {
+				errs = append(errs, ValidationError{path, line,
+					fmt.Sprintf("element not allowed inside %s", parent.path)})
+				d.Skip()
+				continue
+			}
+			if t.Name.Space != Namespace {
+				errs = append(errs, ValidationError{path, line,
+					fmt.Sprintf("element is not in namespace %s", Namespace)})
+				d.Skip()
+				continue
+			}
+
+			idx := -1
+			for i, e := range parent.typ.elements {
+				if e.name == t.Name.Local {
+					idx = i
+					break
+				}
+			}
+			if idx < 0 {
+				errs = append(errs, ValidationError{path, line, "unexpected element"})
+				d.Skip()
+				continue
+			}
+
+			el := parent.typ.elements[idx]
+			parent.counts[el.name]++
+			n := parent.counts[el.name]
+			if el.max != 1 {
+				path = fmt.Sprintf("%s[%d]", path, n)
+			}
+			if !parent.typ.unordered {
+				if idx < parent.pos {
+					errs = append(errs, ValidationError{path, line,
+						fmt.Sprintf("element must appear before %s", parent.typ.elements[parent.pos].name)})
+				} else {
+					parent.pos = idx
+				}
+			}
+			if el.max != unbounded && n > el.max {
+				errs = append(errs, ValidationError{path, line,
+					fmt.Sprintf("element may appear at most %d times", el.max)})
+			}
+
+			errs = append(errs, checkAttributes(t, path, line)...)
+			stack = append(stack, &validationFrame{path: path, line: line, typ: el.typ, counts: map[string]int{}})
+
+		case xml.CharData:
+			top := stack[len(stack)-1]
+			if top.typ.check != nil {
+				top.text.Write(t)
+			} else if len(bytes.TrimSpace(t)) > 0 && !top.hasText {
+				top.hasText = true
+				errs = append(errs, ValidationError{top.path, line, "unexpected text content"})
+			}
+
+		case xml.EndElement:
+			top := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+
+			if top.typ.check != nil {
+				if err := top.typ.check(top.text.String()); err != nil {
+					errs = append(errs, ValidationError{top.path, top.line,
+						fmt.Sprintf("invalid value %q: %v", top.text.String(), err)})
+				}
+			}
+			for _, e := range top.typ.elements {
+				if top.counts[e.name] < e.min {
+					errs = append(errs, ValidationError{top.path, top.line,
+						fmt.Sprintf("missing required element %s", e.name)})
+				}
+			}
+			if len(stack) == 0 {
+				return errs
+			}
+		}
+	}
+}
+
+// checkAttributes reports attributes other than namespace declarations and
+// xsi attributes such as schemaLocation, none of which the schema defines.
+func checkAttributes(t xml.StartElement, path string, line int) []ValidationError {
+	var errs []ValidationError
+	for _, a := range t.Attr {
+		if a.Name.Space == "xmlns" || (a.Name.Space == "" && a.Name.Local == "xmlns") || a.Name.Space == xsiNamespace {
+			continue
+		}
+		errs = append(errs, ValidationError{path, line,
+			fmt.Sprintf("unexpected attribute %s", a.Name.Local)})
+	}
+	return errs
+}
+
+// ValidateHeader serializes head and validates the result.
+func ValidateHeader(head *IsmrmrdHeader) ([]ValidationError, error) {
+	b, err := Serialize(head)
+	if err != nil {
+		return nil, err
+	}
+	return Validate(b), nil
+}
@@ -0,0 +1,144 @@
+package ismrmrd
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidateValid(t *testing.T) {
+	for name, doc := range map[string]string{"test_data.xml": testXML, "modern": modernXML} {
+		if errs := Validate([]byte(doc)); errs != nil {
+			t.Fatalf("%s: unexpected validation errors %v", name, errs)
+		}
+	}
+
+	errs, err := ValidateHeader(testHeader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if errs != nil {
+		t.Fatalf("unexpected validation errors %v", errs)
+	}
+}
+
+// minimalXML leaves out every optional element it can.
+const minimalXML = `<ismrmrdHeader xmlns="http://www.ismrm.org/ISMRMRD">
+  <subjectInformation>
+    <patientID>anonymous</patientID>
+  </subjectInformation>
+  <studyInformation>
+    <studyID>1</studyID>
+  </studyInformation>
+  <acquisitionSystemInformation/>
+  <experimentalConditions>
+    <H1resonanceFrequency_Hz>63500000</H1resonanceFrequency_Hz>
+  </experimentalConditions>
+  <encoding>
+    <encodedSpace>
+      <matrixSize><x>1</x><y>1</y><z>1</z></matrixSize>
+      <fieldOfView_mm><x>1</x><y>1</y><z>1</z></fieldOfView_mm>
+    </encodedSpace>
+    <reconSpace>
+      <matrixSize><x>1</x><y>1</y><z>1</z></matrixSize>
+      <fieldOfView_mm><x>1</x><y>1</y><z>1</z></fieldOfView_mm>
+    </reconSpace>
+    <encodingLimits/>
+    <trajectory>cartesian</trajectory>
+    <parallelImaging>
+      <accelerationFactor>
+        <kspace_encoding_step_1>2</kspace_encoding_step_1>
+        <kspace_encoding_step_2>1</kspace_encoding_step_2>
+      </accelerationFactor>
+    </parallelImaging>
+  </encoding>
+</ismrmrdHeader>`
+
+func TestValidateHeaderRoundTrip(t *testing.T) {
+	if errs := Validate([]byte(minimalXML)); errs != nil {
+		t.Fatalf("unexpected validation errors %v", errs)
+	}
+	head, err := Deserialize([]byte(minimalXML))
+	if err != nil {
+		t.Fatal(err)
+	}
+	errs, err := ValidateHeader(head)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if errs != nil {
+		t.Fatalf("unexpected validation errors after a round trip %v", errs)
+	}
+}
+
+const invalidXML = `<ismrmrdHeader xmlns="http://www.ismrm.org/ISMRMRD">
+  <subjectInformation>
+    <patientGender>X</patientGender>
+  </subjectInformation>
+  <acquisitionSystemInformation>
+    <receiverChannels>70000</receiverChannels>
+    <systemVendor>ACME</systemVendor>
+  </acquisitionSystemInformation>
+  <encoding>
+    <encodedSpace>
+      <matrixSize><x>1</x><y>1</y><z>1</z></matrixSize>
+      <fieldOfView_mm><x>1</x><y>1</y><z>1</z></fieldOfView_mm>
+    </encodedSpace>
+    <reconSpace>
+      <matrixSize><x>1</x><y>1</y><z>1</z></matrixSize>
+      <fieldOfView_mm><x>1</x><y>1</y><z>1</z></fieldOfView_mm>
+    </reconSpace>
+    <encodingLimits/>
+    <trajectory>cartesain</trajectory>
+    <echoTrainLenght>4</echoTrainLenght>
+  </encoding>
+</ismrmrdHeader>`
+
+func TestValidateInvalid(t *testing.T) {
+	errs := Validate([]byte(invalidXML))
+
+	want := []ValidationError{
+		{"/ismrmrdHeader/subjectInformation/patientGender", 3, "invalid value"},
+		{"/ismrmrdHeader/acquisitionSystemInformation/receiverChannels", 6, "out of range for unsignedShort"},
+		{"/ismrmrdHeader/acquisitionSystemInformation/systemVendor", 7, "must appear before receiverChannels"},
+		{"/ismrmrdHeader/encoding[1]/trajectory", 19, "must be one of"},
+		{"/ismrmrdHeader/encoding[1]/echoTrainLenght", 20, "unexpected element"},
+		{"/ismrmrdHeader", 1, "missing required element experimentalConditions"},
+	}
+	if len(errs) != len(want) {
+		t.Fatalf("expected %d errors, found %d: %v", len(want), len(errs), errs)
+	}
+	for i, w := range want {
+		e := errs[i]
+		if e.Path != w.Path || e.Line != w.Line || !strings.Contains(e.Message, w.Message) {
+			t.Fatalf("error %d is %v, expected %v", i, e, w)
+		}
+	}
+}
+
+func TestValidateNamespace(t *testing.T) {
+	errs := Validate([]byte(`<ismrmrdHeader><version>1</version></ismrmrdHeader>`))
+	if len(errs) != 1 || errs[0].Path != "/ismrmrdHeader" {
+		t.Fatalf("expected a single namespace error, found %v", errs)
+	}
+
+	errs = Validate([]byte("<ismrmrdHeader xmlns=\"http://www.ismrm.org/ISMRMRD\">\n<version>1"))
+	if len(errs) != 1 || errs[0].Line != 2 {
+		t.Fatalf("expected a single syntax error on line 2, found %v", errs)
+	}
+}
+
+func TestValidateLargeHeader(t *testing.T) {
+	// Lines are counted once, so a large header validates in linear time.
+	var params strings.Builder
+	for i := 0; i < 20000; i++ {
+		params.WriteString("  <userParameterLong><name>p</name><value>1</value></userParameterLong>\n")
+	}
+	params.WriteString("  <userParameterLong><name>p</name><value>x</value></userParameterLong>\n")
+	doc := strings.Replace(testXML, "<userParameters>\n", "<userParameters>\n"+params.String(), 1)
+
+	errs := Validate([]byte(doc))
+	line := 1 + strings.Count(doc[:strings.Index(doc, "<value>x")], "\n")
+	if len(errs) != 1 || errs[0].Line != line {
+		t.Fatalf("expected a single error on line %d, found %v", line, errs)
+	}
+}
@@ -9,172 +9,268 @@ const Namespace = "http://www.ismrm.org/ISMRMRD"
 // xmlns:xsi="http://www.w3.org/2001/XMLSchema-instance" xmlns:xs="http://www.w3.org/2001/XMLSchema" xsi:schemaLocation="http://www.ismrm.org/ISMRMRD ismrmrd.xsd"`
 
 type IsmrmrdHeader struct {
-	XMLName                      xml.Name
-	Version                      int64                         `xml:"version"`
-	SubjectInformation           *SubjectInformation           `xml:"subjectInformation"`
-	StudyInformation             *StudyInformation             `xml:"studyInformation"`
-	MeasurementInformation       *MeasurementInformation       `xml:"measurementInformation"`
-	AcquisitionSystemInformation *AcquisitionSystemInformation `xml:"acquisitionSystemInformation"`
-	ExperimentalConditions       ExperimentalConditions        `xml:"experimentalConditions"`
-	Encoding                     []Encoding                    `xml:"encoding"`
-	SequenceParameters           *SequenceParameters           `xml:"sequenceParameters"`
-	UserParameters               *UserParameters               `xml:"userParameters"`
+	XMLName                      xml.Name                      `json:"-"`
+	Version                      *int64                        `xml:"version,omitempty" json:"version,omitempty"`
+	SubjectInformation           *SubjectInformation           `xml:"subjectInformation" json:"subjectInformation,omitempty"`
+	StudyInformation             *StudyInformation             `xml:"studyInformation" json:"studyInformation,omitempty"`
+	MeasurementInformation       *MeasurementInformation       `xml:"measurementInformation" json:"measurementInformation,omitempty"`
+	AcquisitionSystemInformation *AcquisitionSystemInformation `xml:"acquisitionSystemInformation" json:"acquisitionSystemInformation,omitempty"`
+	ExperimentalConditions       ExperimentalConditions        `xml:"experimentalConditions" json:"experimentalConditions"`
+	Encoding                     []Encoding                    `xml:"encoding" json:"encoding,omitempty"`
+	SequenceParameters           *SequenceParameters           `xml:"sequenceParameters" json:"sequenceParameters,omitempty"`
+	UserParameters               *UserParameters               `xml:"userParameters" json:"userParameters,omitempty"`
+	WaveformInformation          []WaveformInformation         `xml:"waveformInformation" json:"waveformInformation,omitempty"`
+	Extensions                   *Extensions                   `xml:"-" json:"extensions,omitempty"`
 }
 
 type SubjectInformation struct {
-	PatientName      string  `xml:"patientName"`
-	PatientWeightKg  float32 `xml:"patientWeight_kg"`
-	PatientID        string  `xml:"patientID"`
-	PatientBirthdate string  `xml:"patientBirthdate"`
-	PatientGender    string  `xml:"patientGender"`
+	PatientName      string      `xml:"patientName,omitempty" json:"patientName,omitempty"`
+	PatientWeightKg  *float32    `xml:"patientWeight_kg,omitempty" json:"patientWeight_kg,omitempty"`
+	PatientHeightM   *float32    `xml:"patientHeight_m,omitempty" json:"patientHeight_m,omitempty"`
+	PatientID        string      `xml:"patientID,omitempty" json:"patientID,omitempty"`
+	PatientBirthdate string      `xml:"patientBirthdate,omitempty" json:"patientBirthdate,omitempty"`
+	PatientGender    string      `xml:"patientGender,omitempty" json:"patientGender,omitempty"`
+	Extensions       *Extensions `xml:"-" json:"extensions,omitempty"`
 }
 
 type StudyInformation struct {
-	StudyDate              string `xml:"studyDate"`
-	StudyTime              string `xml:"studyTime"`
-	StudyID                string `xml:"studyID"`
-	AccessionNumber        int64  `xml:"accessionNumber"`
-	ReferringPhysicianName string `xml:"referringPhysicianName"`
-	StudyDescription       string `xml:"studyDescription"`
-	StudyInstanceUID       string `xml:"studyInstanceUID"`
+	StudyDate              string      `xml:"studyDate,omitempty" json:"studyDate,omitempty"`
+	StudyTime              string      `xml:"studyTime,omitempty" json:"studyTime,omitempty"`
+	StudyID                string      `xml:"studyID,omitempty" json:"studyID,omitempty"`
+	AccessionNumber        *int64      `xml:"accessionNumber,omitempty" json:"accessionNumber,omitempty"`
+	ReferringPhysicianName string      `xml:"referringPhysicianName,omitempty" json:"referringPhysicianName,omitempty"`
+	StudyDescription       string      `xml:"studyDescription,omitempty" json:"studyDescription,omitempty"`
+	StudyInstanceUID       string      `xml:"studyInstanceUID,omitempty" json:"studyInstanceUID,omitempty"`
+	BodyPartExamined       string      `xml:"bodyPartExamined,omitempty" json:"bodyPartExamined,omitempty"`
+	Extensions             *Extensions `xml:"-" json:"extensions,omitempty"`
 }
 
 type MeasurementInformation struct {
-	MeasurementID           string                  `xml:"measurementID"`
-	SeriesDate              string                  `xml:"seriesDate"`
-	SeriesTime              string                  `xml:"seriesTime"`
-	PatientPosition         string                  `xml:"patientPosition"`
-	InitialSeriesNumber     int64                   `xml:"initialSeriesNumber"`
-	ProtocolName            string                  `xml:"protocolName"`
-	SeriesDescription       string                  `xml:"seriesDescription"`
-	MeasurementDependency   []MeasurementDependency `xml:"measurementDependency"`
-	SeriesInstanceUIDRoot   string                  `xml:"seriesInstanceUIDRoot"`
-	FrameOfReferenceUID     string                  `xml:"frameOfReferenceUID"`
-	ReferencedImageSequence ReferencedImageSequence `xml:"referencedImageSequence"`
+	MeasurementID           string                   `xml:"measurementID,omitempty" json:"measurementID,omitempty"`
+	SeriesDate              string                   `xml:"seriesDate,omitempty" json:"seriesDate,omitempty"`
+	SeriesTime              string                   `xml:"seriesTime,omitempty" json:"seriesTime,omitempty"`
+	PatientPosition         string                   `xml:"patientPosition" json:"patientPosition"`
+	RelativeTablePosition   *ThreeDimensionalFloat   `xml:"relativeTablePosition" json:"relativeTablePosition,omitempty"`
+	InitialSeriesNumber     *int64                   `xml:"initialSeriesNumber,omitempty" json:"initialSeriesNumber,omitempty"`
+	ProtocolName            string                   `xml:"protocolName,omitempty" json:"protocolName,omitempty"`
+	SequenceName            string                   `xml:"sequenceName,omitempty" json:"sequenceName,omitempty"`
+	SeriesDescription       string                   `xml:"seriesDescription,omitempty" json:"seriesDescription,omitempty"`
+	MeasurementDependency   []MeasurementDependency  `xml:"measurementDependency" json:"measurementDependency,omitempty"`
+	SeriesInstanceUIDRoot   string                   `xml:"seriesInstanceUIDRoot,omitempty" json:"seriesInstanceUIDRoot,omitempty"`
+	FrameOfReferenceUID     string                   `xml:"frameOfReferenceUID,omitempty" json:"frameOfReferenceUID,omitempty"`
+	ReferencedImageSequence *ReferencedImageSequence `xml:"referencedImageSequence" json:"referencedImageSequence,omitempty"`
+	Extensions              *Extensions              `xml:"-" json:"extensions,omitempty"`
+}
+
+type ThreeDimensionalFloat struct {
+	X float32 `xml:"x" json:"x"`
+	Y float32 `xml:"y" json:"y"`
+	Z float32 `xml:"z" json:"z"`
 }
 
 type MeasurementDependency struct {
-	DependencyType string `xml:"dependencyType"`
-	MeasurementID  string `xml:"measurementID"`
+	DependencyType string `xml:"dependencyType" json:"dependencyType"`
+	MeasurementID  string `xml:"measurementID" json:"measurementID"`
 }
 
 type ReferencedImageSequence struct {
-	ReferencedSOPInstanceUID []string `xml:"referencedSOPInstanceUID"`
+	ReferencedSOPInstanceUID []string `xml:"referencedSOPInstanceUID" json:"referencedSOPInstanceUID,omitempty"`
 }
 
 type AcquisitionSystemInformation struct {
-	SystemVendor                  string  `xml:"systemVendor"`
-	SystemModel                   string  `xml:"systemModel"`
-	SystemFieldStrengthT          float32 `xml:"systemFieldStrength_T"`
-	RelativeReceiverNoiseBandwith float32 `xml:"relativeReceiverNoiseBandwidth"`
-	ReceiverChannels              uint16  `xml:"receiverChannels"`
-	InstitutionName               string  `xml:"institutionName"`
-	StationName                   string  `xml:"stationName"`
+	SystemVendor                  string      `xml:"systemVendor,omitempty" json:"systemVendor,omitempty"`
+	SystemModel                   string      `xml:"systemModel,omitempty" json:"systemModel,omitempty"`
+	SystemFieldStrengthT          *float32    `xml:"systemFieldStrength_T,omitempty" json:"systemFieldStrength_T,omitempty"`
+	RelativeReceiverNoiseBandwith *float32    `xml:"relativeReceiverNoiseBandwidth,omitempty" json:"relativeReceiverNoiseBandwidth,omitempty"`
+	ReceiverChannels              *uint16     `xml:"receiverChannels,omitempty" json:"receiverChannels,omitempty"`
+	CoilLabel                     []CoilLabel `xml:"coilLabel" json:"coilLabel,omitempty"`
+	InstitutionName               string      `xml:"institutionName,omitempty" json:"institutionName,omitempty"`
+	StationName                   string      `xml:"stationName,omitempty" json:"stationName,omitempty"`
+	DeviceID                      string      `xml:"deviceID,omitempty" json:"deviceID,omitempty"`
+	DeviceSerialNumber            string      `xml:"deviceSerialNumber,omitempty" json:"deviceSerialNumber,omitempty"`
+	Extensions                    *Extensions `xml:"-" json:"extensions,omitempty"`
+}
+
+type CoilLabel struct {
+	CoilNumber uint16 `xml:"coilNumber" json:"coilNumber"`
+	CoilName   string `xml:"coilName" json:"coilName"`
 }
 
 type ExperimentalConditions struct {
-	H1ResonanceFrequencyHz int64 `xml:"H1resonanceFrequency_Hz"`
+	H1ResonanceFrequencyHz int64 `xml:"H1resonanceFrequency_Hz" json:"H1resonanceFrequency_Hz"`
 }
 
 type Encoding struct {
-	EncodedSpace          EncodingSpace          `xml:"encodedSpace"`
-	ReconSpace            EncodingSpace          `xml:"reconSpace"`
-	EncodingLimits        EncodingLimits         `xml:"encodingLimits"`
-	Trajectory            string                 `xml:"trajectory"`
-	TrajectoryDescription *TrajectoryDescription `xml:"trajectoryDescription"`
-	ParallelImaging       *ParallelImaging       `xml:"parallelImaging"`
+	EncodedSpace          EncodingSpace          `xml:"encodedSpace" json:"encodedSpace"`
+	ReconSpace            EncodingSpace          `xml:"reconSpace" json:"reconSpace"`
+	EncodingLimits        EncodingLimits         `xml:"encodingLimits" json:"encodingLimits"`
+	Trajectory            string                 `xml:"trajectory" json:"trajectory"`
+	TrajectoryDescription *TrajectoryDescription `xml:"trajectoryDescription" json:"trajectoryDescription,omitempty"`
+	ParallelImaging       *ParallelImaging       `xml:"parallelImaging" json:"parallelImaging,omitempty"`
+	EchoTrainLength       *int64                 `xml:"echoTrainLength,omitempty" json:"echoTrainLength,omitempty"`
+	Extensions            *Extensions            `xml:"-" json:"extensions,omitempty"`
 }
 
 type EncodingSpace struct {
-	MatrixSize    MatrixSize  `xml:"matrixSize"`
-	FieldOfViewMM FieldOfView `xml:"fieldOfView_mm"`
+	MatrixSize    MatrixSize  `xml:"matrixSize" json:"matrixSize"`
+	FieldOfViewMM FieldOfView `xml:"fieldOfView_mm" json:"fieldOfView_mm"`
 }
 
 type MatrixSize struct {
-	X uint16 `xml:"x"`
-	Y uint16 `xml:"y"`
-	Z uint16 `xml:"z"`
+	X uint16 `xml:"x" json:"x"`
+	Y uint16 `xml:"y" json:"y"`
+	Z uint16 `xml:"z" json:"z"`
 }
 
 type FieldOfView struct {
-	X float32 `xml:"x"`
-	Y float32 `xml:"y"`
-	Z float32 `xml:"z"`
+	X float32 `xml:"x" json:"x"`
+	Y float32 `xml:"y" json:"y"`
+	Z float32 `xml:"z" json:"z"`
 }
 
 type EncodingLimits struct {
-	KSpaceEncodingStep0 *Limit `xml:"kspace_encoding_step_0"`
-	KSpaceEncodingStep1 *Limit `xml:"kspace_encoding_step_1"`
-	KSpaceEncodingStep2 *Limit `xml:"kspace_encoding_step_2"`
-	Average             *Limit `xml:"average"`
-	Slice               *Limit `xml:"slice"`
-	Contrast            *Limit `xml:"contrast"`
-	Phase               *Limit `xml:"phase"`
-	Repetition          *Limit `xml:"repetition"`
-	Set                 *Limit `xml:"set"`
-	Segment             *Limit `xml:"segment"`
+	KSpaceEncodingStep0 *Limit      `xml:"kspace_encoding_step_0" json:"kspace_encoding_step_0,omitempty"`
+	KSpaceEncodingStep1 *Limit      `xml:"kspace_encoding_step_1" json:"kspace_encoding_step_1,omitempty"`
+	KSpaceEncodingStep2 *Limit      `xml:"kspace_encoding_step_2" json:"kspace_encoding_step_2,omitempty"`
+	Average             *Limit      `xml:"average" json:"average,omitempty"`
+	Slice               *Limit      `xml:"slice" json:"slice,omitempty"`
+	Contrast            *Limit      `xml:"contrast" json:"contrast,omitempty"`
+	Phase               *Limit      `xml:"phase" json:"phase,omitempty"`
+	Repetition          *Limit      `xml:"repetition" json:"repetition,omitempty"`
+	Set                 *Limit      `xml:"set" json:"set,omitempty"`
+	Segment             *Limit      `xml:"segment" json:"segment,omitempty"`
+	User0               *Limit      `xml:"user_0" json:"user_0,omitempty"`
+	User1               *Limit      `xml:"user_1" json:"user_1,omitempty"`
+	User2               *Limit      `xml:"user_2" json:"user_2,omitempty"`
+	User3               *Limit      `xml:"user_3" json:"user_3,omitempty"`
+	User4               *Limit      `xml:"user_4" json:"user_4,omitempty"`
+	User5               *Limit      `xml:"user_5" json:"user_5,omitempty"`
+	User6               *Limit      `xml:"user_6" json:"user_6,omitempty"`
+	User7               *Limit      `xml:"user_7" json:"user_7,omitempty"`
+	Extensions          *Extensions `xml:"-" json:"extensions,omitempty"`
 }
 
 type Limit struct {
-	Minimum uint16 `xml:"minimum"`
-	Maximum uint16 `xml:"maximum"`
-	Center  uint16 `xml:"center"`
+	Minimum uint16 `xml:"minimum" json:"minimum"`
+	Maximum uint16 `xml:"maximum" json:"maximum"`
+	Center  uint16 `xml:"center" json:"center"`
 }
 
 type TrajectoryDescription struct {
-	Name                string                `xml:"name"`
-	UserParameterLong   []UserParameterLong   `xml:"userParameterLong"`
-	UserParameterDouble []UserParameterDouble `xml:"userParameterDouble"`
-	Comment             string                `xml:"comment"`
+	Identifier          string                `xml:"identifier" json:"identifier"`
+	UserParameterLong   []UserParameterLong   `xml:"userParameterLong" json:"userParameterLong,omitempty"`
+	UserParameterDouble []UserParameterDouble `xml:"userParameterDouble" json:"userParameterDouble,omitempty"`
+	UserParameterString []UserParameterString `xml:"userParameterString" json:"userParameterString,omitempty"`
+	Comment             string                `xml:"comment,omitempty" json:"comment,omitempty"`
+	Extensions          *Extensions           `xml:"-" json:"extensions,omitempty"`
 }
 
 type ParallelImaging struct {
-	AccelerationFactor    AccelerationFactor `xml:"accelerationFactor"`
-	CalibrationMode       string             `xml:"calibrationMode"`
-	InterleavingDimension string             `xml:"interleavingDimension"`
+	AccelerationFactor    AccelerationFactor `xml:"accelerationFactor" json:"accelerationFactor"`
+	CalibrationMode       string             `xml:"calibrationMode,omitempty" json:"calibrationMode,omitempty"`
+	InterleavingDimension string             `xml:"interleavingDimension,omitempty" json:"interleavingDimension,omitempty"`
+	Multiband             *Multiband         `xml:"multiband" json:"multiband,omitempty"`
+	Extensions            *Extensions        `xml:"-" json:"extensions,omitempty"`
 }
 
 type AccelerationFactor struct {
-	KSpaceEncodingStep1 uint16 `xml:"kspace_encoding_step_1"`
-	KSpaceEncodingStep2 uint16 `xml:"kspace_encoding_step_2"`
+	KSpaceEncodingStep1 uint16 `xml:"kspace_encoding_step_1" json:"kspace_encoding_step_1"`
+	KSpaceEncodingStep2 uint16 `xml:"kspace_encoding_step_2" json:"kspace_encoding_step_2"`
+}
+
+// Multiband calibration modes
+const (
+	MultibandCalibrationSeparable2D = "separable2D"
+	MultibandCalibrationFull3D      = "full3D"
+	MultibandCalibrationOther       = "other"
+)
+
+type Multiband struct {
+	Spacing             []MultibandSpacing `xml:"spacing" json:"spacing,omitempty"`
+	DeltaKz             float32            `xml:"deltaKz" json:"deltaKz"`
+	MultibandFactor     uint32             `xml:"multiband_factor" json:"multiband_factor"`
+	Calibration         string             `xml:"calibration" json:"calibration"`
+	CalibrationEncoding uint64             `xml:"calibration_encoding" json:"calibration_encoding"`
+	Extensions          *Extensions        `xml:"-" json:"extensions,omitempty"`
+}
+
+type MultibandSpacing struct {
+	DZ []float32 `xml:"dZ" json:"dZ,omitempty"`
 }
 
 type SequenceParameters struct {
-	TR           []float32
-	TE           []float32
-	TI           []float32
-	FlipAngleDeg []float32 `xml:"flipAngle_deg"`
+	TR                 []float32   `xml:"TR" json:"TR,omitempty"`
+	TE                 []float32   `xml:"TE" json:"TE,omitempty"`
+	TI                 []float32   `xml:"TI" json:"TI,omitempty"`
+	FlipAngleDeg       []float32   `xml:"flipAngle_deg" json:"flipAngle_deg,omitempty"`
+	SequenceType       string      `xml:"sequence_type,omitempty" json:"sequence_type,omitempty"`
+	EchoSpacing        []float32   `xml:"echo_spacing" json:"echo_spacing,omitempty"`
+	DiffusionDimension string      `xml:"diffusionDimension,omitempty" json:"diffusionDimension,omitempty"`
+	Diffusion          []Diffusion `xml:"diffusion" json:"diffusion,omitempty"`
+	DiffusionScheme    string      `xml:"diffusionScheme,omitempty" json:"diffusionScheme,omitempty"`
+	Extensions         *Extensions `xml:"-" json:"extensions,omitempty"`
+}
+
+type Diffusion struct {
+	GradientDirection GradientDirection `xml:"gradientDirection" json:"gradientDirection"`
+	Bvalue            float32           `xml:"bvalue" json:"bvalue"`
+}
+
+type GradientDirection struct {
+	RL float32 `xml:"rl" json:"rl"`
+	AP float32 `xml:"ap" json:"ap"`
+	FH float32 `xml:"fh" json:"fh"`
 }
 
 type UserParameters struct {
-	UserParameterLong   []UserParameterLong   `xml:"userParameterLong"`
-	UserParameterDouble []UserParameterDouble `xml:"userParameterDouble"`
-	UserParameterString []UserParameterString `xml:"userParameterString"`
-	UserParameterBase64 []UserParameterBase64 `xml:"userParameterBase64"`
+	UserParameterLong   []UserParameterLong   `xml:"userParameterLong" json:"userParameterLong,omitempty"`
+	UserParameterDouble []UserParameterDouble `xml:"userParameterDouble" json:"userParameterDouble,omitempty"`
+	UserParameterString []UserParameterString `xml:"userParameterString" json:"userParameterString,omitempty"`
+	UserParameterBase64 []UserParameterBase64 `xml:"userParameterBase64" json:"userParameterBase64,omitempty"`
+	Extensions          *Extensions           `xml:"-" json:"extensions,omitempty"`
 }
 
 type UserParameterLong struct {
-	Name  string `xml:"name"`
-	Value int64  `xml:"value"`
+	Name  string `xml:"name" json:"name"`
+	Value int64  `xml:"value" json:"value"`
 }
 
 type UserParameterDouble struct {
-	Name  string  `xml:"name"`
-	Value float64 `xml:"value"`
+	Name  string  `xml:"name" json:"name"`
+	Value float64 `xml:"value" json:"value"`
 }
 
 type UserParameterString struct {
-	Name  string `xml:"name"`
-	Value string `xml:"value"`
+	Name  string `xml:"name" json:"name"`
+	Value string `xml:"value" json:"value"`
 }
 
 type UserParameterBase64 struct {
-	Name  string `xml:"name"`
-	Value string `xml:"value"`
+	Name  string `xml:"name" json:"name"`
+	Value string `xml:"value" json:"value"`
+}
+
+// Waveform types
+const (
+	WaveformECG              = "ecg"
+	WaveformPulse            = "pulse"
+	WaveformRespiratory      = "respiratory"
+	WaveformTrigger          = "trigger"
+	WaveformGradientWaveform = "gradientwaveform"
+	WaveformOther            = "other"
+)
+
+type WaveformInformation struct {
+	WaveformName   string          `xml:"waveformName" json:"waveformName"`
+	WaveformType   string          `xml:"waveformType" json:"waveformType"`
+	UserParameters *UserParameters `xml:"userParameters" json:"userParameters,omitempty"`
+	Extensions     *Extensions     `xml:"-" json:"extensions,omitempty"`
 }
 
+// Serialize encodes head as an XML document. Optional elements whose fields
+// hold their zero value are left out.
 func Serialize(head *IsmrmrdHeader) ([]byte, error) {
-	head.XMLName = xml.Name{Namespace, "ismrmrdHeader"}
+	head.XMLName = xml.Name{Space: Namespace, Local: "ismrmrdHeader"}
 	return xml.MarshalIndent(head, "", "  ")
 }
 
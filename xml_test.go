@@ -4,6 +4,8 @@ import (
 	"fmt"
 	"io/ioutil"
 	"os"
+	"reflect"
+	"strings"
 	"testing"
 )
 
@@ -82,6 +84,10 @@ var userParam4 = UserParameterString{"mrAcquisitionType", "2D"}
 var userParam5 = UserParameterString{"freqEncodingDirection", "COL"}
 var userParam6 = UserParameterDouble{"triggerTime", 0.0}
 
+func newInt64(v int64) *int64       { return &v }
+func newUint16(v uint16) *uint16    { return &v }
+func newFloat32(v float32) *float32 { return &v }
+
 var testXML string
 var testHeader *IsmrmrdHeader
 
@@ -99,27 +105,46 @@ func TestMain(m *testing.M) {
 	testXML = string(b)
 
 	testHeader = &IsmrmrdHeader{
-		Version: version,
+		Version: newInt64(version),
 		SubjectInformation: &SubjectInformation{
-			patientName, patientWeight, patientID, patientBirthdate, patientGender,
+			PatientName:      patientName,
+			PatientWeightKg:  newFloat32(patientWeight),
+			PatientID:        patientID,
+			PatientBirthdate: patientBirthdate,
+			PatientGender:    patientGender,
 		},
 		StudyInformation: &StudyInformation{
-			studyDate, studyTime, studyID, accessionNumber,
-			referringPhysicianName, studyDescription, studyInstanceUID,
+			StudyDate:              studyDate,
+			StudyTime:              studyTime,
+			StudyID:                studyID,
+			AccessionNumber:        newInt64(accessionNumber),
+			ReferringPhysicianName: referringPhysicianName,
+			StudyDescription:       studyDescription,
+			StudyInstanceUID:       studyInstanceUID,
 		},
 		MeasurementInformation: &MeasurementInformation{
-			measurementID, seriesDate, seriesTime, patientPosition,
-			initialSeriesNumber, protocolName, seriesDescription, nil,
-			seriesInstanceUIDRoot, frameOfReferenceUID,
-			ReferencedImageSequence{[]string{
+			MeasurementID:         measurementID,
+			SeriesDate:            seriesDate,
+			SeriesTime:            seriesTime,
+			PatientPosition:       patientPosition,
+			InitialSeriesNumber:   newInt64(initialSeriesNumber),
+			ProtocolName:          protocolName,
+			SeriesDescription:     seriesDescription,
+			SeriesInstanceUIDRoot: seriesInstanceUIDRoot,
+			FrameOfReferenceUID:   frameOfReferenceUID,
+			ReferencedImageSequence: &ReferencedImageSequence{[]string{
 				referencedImageSequence0, referencedImageSequence1,
 				referencedImageSequence2, referencedImageSequence3,
 			}},
 		},
 		AcquisitionSystemInformation: &AcquisitionSystemInformation{
-			systemVendor, systemModel, systemFieldStrengthT,
-			relativeReceiverNoiseBandwidth, receiverChannels,
-			institutionName, stationName,
+			SystemVendor:                  systemVendor,
+			SystemModel:                   systemModel,
+			SystemFieldStrengthT:          newFloat32(systemFieldStrengthT),
+			RelativeReceiverNoiseBandwith: newFloat32(relativeReceiverNoiseBandwidth),
+			ReceiverChannels:              newUint16(receiverChannels),
+			InstitutionName:               institutionName,
+			StationName:                   stationName,
 		},
 		ExperimentalConditions: ExperimentalConditions{h1ResonanceFrequencyHz},
 	}
@@ -147,7 +172,11 @@ func TestMain(m *testing.M) {
 	testHeader.Encoding = append(testHeader.Encoding, e)
 
 	testHeader.SequenceParameters = &SequenceParameters{
-		[]float32{tr0}, []float32{te0, te1}, []float32{ti0}, []float32{flipAngle0}}
+		TR:           []float32{tr0},
+		TE:           []float32{te0, te1},
+		TI:           []float32{ti0},
+		FlipAngleDeg: []float32{flipAngle0},
+	}
 
 	testHeader.UserParameters = &UserParameters{
 		UserParameterString: []UserParameterString{
@@ -161,27 +190,46 @@ func TestMain(m *testing.M) {
 
 func TestSerialize(t *testing.T) {
 	head := &IsmrmrdHeader{
-		Version: version,
+		Version: newInt64(version),
 		SubjectInformation: &SubjectInformation{
-			patientName, patientWeight, patientID, patientBirthdate, patientGender,
+			PatientName:      patientName,
+			PatientWeightKg:  newFloat32(patientWeight),
+			PatientID:        patientID,
+			PatientBirthdate: patientBirthdate,
+			PatientGender:    patientGender,
 		},
 		StudyInformation: &StudyInformation{
-			studyDate, studyTime, studyID, accessionNumber,
-			referringPhysicianName, studyDescription, studyInstanceUID,
+			StudyDate:              studyDate,
+			StudyTime:              studyTime,
+			StudyID:                studyID,
+			AccessionNumber:        newInt64(accessionNumber),
+			ReferringPhysicianName: referringPhysicianName,
+			StudyDescription:       studyDescription,
+			StudyInstanceUID:       studyInstanceUID,
 		},
 		MeasurementInformation: &MeasurementInformation{
-			measurementID, seriesDate, seriesTime, patientPosition,
-			initialSeriesNumber, protocolName, seriesDescription, nil,
-			seriesInstanceUIDRoot, frameOfReferenceUID,
-			ReferencedImageSequence{[]string{
+			MeasurementID:         measurementID,
+			SeriesDate:            seriesDate,
+			SeriesTime:            seriesTime,
+			PatientPosition:       patientPosition,
+			InitialSeriesNumber:   newInt64(initialSeriesNumber),
+			ProtocolName:          protocolName,
+			SeriesDescription:     seriesDescription,
+			SeriesInstanceUIDRoot: seriesInstanceUIDRoot,
+			FrameOfReferenceUID:   frameOfReferenceUID,
+			ReferencedImageSequence: &ReferencedImageSequence{[]string{
 				referencedImageSequence0, referencedImageSequence1,
 				referencedImageSequence2, referencedImageSequence3,
 			}},
 		},
 		AcquisitionSystemInformation: &AcquisitionSystemInformation{
-			systemVendor, systemModel, systemFieldStrengthT,
-			relativeReceiverNoiseBandwidth, receiverChannels,
-			institutionName, stationName,
+			SystemVendor:                  systemVendor,
+			SystemModel:                   systemModel,
+			SystemFieldStrengthT:          newFloat32(systemFieldStrengthT),
+			RelativeReceiverNoiseBandwith: newFloat32(relativeReceiverNoiseBandwidth),
+			ReceiverChannels:              newUint16(receiverChannels),
+			InstitutionName:               institutionName,
+			StationName:                   stationName,
 		},
 		ExperimentalConditions: ExperimentalConditions{h1ResonanceFrequencyHz},
 	}
@@ -209,7 +257,11 @@ func TestSerialize(t *testing.T) {
 	head.Encoding = append(head.Encoding, e)
 
 	head.SequenceParameters = &SequenceParameters{
-		[]float32{tr0}, []float32{te0, te1}, []float32{ti0}, []float32{flipAngle0}}
+		TR:           []float32{tr0},
+		TE:           []float32{te0, te1},
+		TI:           []float32{ti0},
+		FlipAngleDeg: []float32{flipAngle0},
+	}
 
 	head.UserParameters = &UserParameters{
 		UserParameterString: []UserParameterString{
@@ -242,16 +294,16 @@ func TestDeserialize(t *testing.T) {
 }
 
 func equal(h1, h2 *IsmrmrdHeader) bool {
-	if h1.Version != h2.Version {
+	if !reflect.DeepEqual(h1.Version, h2.Version) {
 		print("Bad version")
 		return false
-	} else if *h1.SubjectInformation != *h2.SubjectInformation {
+	} else if !reflect.DeepEqual(h1.SubjectInformation, h2.SubjectInformation) {
 		print("Bad SubjectInformation")
 		return false
-	} else if *h1.StudyInformation != *h2.StudyInformation {
+	} else if !reflect.DeepEqual(h1.StudyInformation, h2.StudyInformation) {
 		print("Bad StudyInformation")
 		return false
-	} else if *h1.AcquisitionSystemInformation != *h2.AcquisitionSystemInformation {
+	} else if !sameAcquisitionSystemInformation(h1.AcquisitionSystemInformation, h2.AcquisitionSystemInformation) {
 		print("Bad AcquisitionSystemInformation")
 		return false
 	} else if h1.ExperimentalConditions != h2.ExperimentalConditions {
@@ -274,6 +326,27 @@ func equal(h1, h2 *IsmrmrdHeader) bool {
 	return true
 }
 
+func sameAcquisitionSystemInformation(a1, a2 *AcquisitionSystemInformation) bool {
+	if len(a1.CoilLabel) != len(a2.CoilLabel) {
+		return false
+	}
+	for i := range a1.CoilLabel {
+		if a1.CoilLabel[i] != a2.CoilLabel[i] {
+			return false
+		}
+	}
+
+	return a1.SystemVendor == a2.SystemVendor &&
+		a1.SystemModel == a2.SystemModel &&
+		reflect.DeepEqual(a1.SystemFieldStrengthT, a2.SystemFieldStrengthT) &&
+		reflect.DeepEqual(a1.RelativeReceiverNoiseBandwith, a2.RelativeReceiverNoiseBandwith) &&
+		reflect.DeepEqual(a1.ReceiverChannels, a2.ReceiverChannels) &&
+		a1.InstitutionName == a2.InstitutionName &&
+		a1.StationName == a2.StationName &&
+		a1.DeviceID == a2.DeviceID &&
+		a1.DeviceSerialNumber == a2.DeviceSerialNumber
+}
+
 func sameMeasurementInfo(m1, m2 *MeasurementInformation) bool {
 	if len(m1.MeasurementDependency) != len(m2.MeasurementDependency) {
 		return false
@@ -284,22 +357,15 @@ func sameMeasurementInfo(m1, m2 *MeasurementInformation) bool {
 		}
 	}
 
-	if len(m1.ReferencedImageSequence.ReferencedSOPInstanceUID) !=
-		len(m2.ReferencedImageSequence.ReferencedSOPInstanceUID) {
+	if !reflect.DeepEqual(m1.ReferencedImageSequence, m2.ReferencedImageSequence) {
 		return false
 	}
-	for i := 0; i < len(m1.ReferencedImageSequence.ReferencedSOPInstanceUID); i++ {
-		if m1.ReferencedImageSequence.ReferencedSOPInstanceUID[i] !=
-			m2.ReferencedImageSequence.ReferencedSOPInstanceUID[i] {
-			return false
-		}
-	}
 
 	return m1.MeasurementID == m2.MeasurementID &&
 		m1.SeriesDate == m2.SeriesDate &&
 		m1.SeriesTime == m2.SeriesTime &&
 		m1.PatientPosition == m2.PatientPosition &&
-		m1.InitialSeriesNumber == m2.InitialSeriesNumber &&
+		reflect.DeepEqual(m1.InitialSeriesNumber, m2.InitialSeriesNumber) &&
 		m1.ProtocolName == m2.ProtocolName &&
 		m1.SeriesDescription == m2.SeriesDescription &&
 		m1.SeriesInstanceUIDRoot == m2.SeriesInstanceUIDRoot &&
@@ -398,7 +464,7 @@ func sameTrajectoryDescription(t1, t2 *TrajectoryDescription) bool {
 		return false
 	}
 
-	if t1.Name != t2.Name {
+	if t1.Identifier != t2.Identifier {
 		return false
 	}
 	if len(t1.UserParameterLong) != len(t2.UserParameterLong) {
@@ -479,3 +545,275 @@ func sameUserParameters(p1, p2 *UserParameters) bool {
 
 	return true
 }
+
+func TestWaveformInformation(t *testing.T) {
+	head, err := Deserialize([]byte(testXML))
+	if err != nil {
+		t.Fatal(err)
+	}
+	head.WaveformInformation = []WaveformInformation{
+		{WaveformName: "ECG1", WaveformType: WaveformECG},
+		{
+			WaveformName: "PULSE", WaveformType: WaveformPulse,
+			UserParameters: &UserParameters{
+				UserParameterLong: []UserParameterLong{{"channels", 1}},
+			},
+		},
+	}
+
+	b, err := Serialize(head)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	head, err = Deserialize(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(head.WaveformInformation) != 2 {
+		t.Fatalf("expected 2 waveformInformation elements, found %d", len(head.WaveformInformation))
+	}
+	w := head.WaveformInformation[1]
+	if w.WaveformName != "PULSE" || w.WaveformType != WaveformPulse ||
+		w.UserParameters == nil || len(w.UserParameters.UserParameterLong) != 1 {
+		t.Fatalf("waveformInformation does not match what was written (%+v)", w)
+	}
+}
+
+const modernXML = `<ismrmrdHeader xmlns="http://www.ismrm.org/ISMRMRD">
+  <subjectInformation>
+    <patientWeight_kg>70</patientWeight_kg>
+    <patientHeight_m>1.8</patientHeight_m>
+  </subjectInformation>
+  <studyInformation>
+    <bodyPartExamined>HEAD</bodyPartExamined>
+  </studyInformation>
+  <measurementInformation>
+    <patientPosition>HFS</patientPosition>
+    <relativeTablePosition>
+      <x>0</x>
+      <y>0</y>
+      <z>-25.5</z>
+    </relativeTablePosition>
+    <sequenceName>gre</sequenceName>
+  </measurementInformation>
+  <acquisitionSystemInformation>
+    <receiverChannels>2</receiverChannels>
+    <coilLabel>
+      <coilNumber>1</coilNumber>
+      <coilName>H1</coilName>
+    </coilLabel>
+    <coilLabel>
+      <coilNumber>2</coilNumber>
+      <coilName>H2</coilName>
+    </coilLabel>
+    <deviceID>12345</deviceID>
+  </acquisitionSystemInformation>
+  <experimentalConditions>
+    <H1resonanceFrequency_Hz>123136640</H1resonanceFrequency_Hz>
+  </experimentalConditions>
+  <encoding>
+    <encodedSpace>
+      <matrixSize>
+        <x>64</x>
+        <y>64</y>
+        <z>1</z>
+      </matrixSize>
+      <fieldOfView_mm>
+        <x>220</x>
+        <y>220</y>
+        <z>5</z>
+      </fieldOfView_mm>
+    </encodedSpace>
+    <reconSpace>
+      <matrixSize>
+        <x>64</x>
+        <y>64</y>
+        <z>1</z>
+      </matrixSize>
+      <fieldOfView_mm>
+        <x>220</x>
+        <y>220</y>
+        <z>5</z>
+      </fieldOfView_mm>
+    </reconSpace>
+    <encodingLimits>
+      <user_3>
+        <minimum>0</minimum>
+        <maximum>7</maximum>
+        <center>0</center>
+      </user_3>
+    </encodingLimits>
+    <trajectory>spiral</trajectory>
+    <trajectoryDescription>
+      <identifier>HargreavesVDS2000</identifier>
+      <userParameterString>
+        <name>gradients</name>
+        <value>vds</value>
+      </userParameterString>
+    </trajectoryDescription>
+    <parallelImaging>
+      <accelerationFactor>
+        <kspace_encoding_step_1>2</kspace_encoding_step_1>
+        <kspace_encoding_step_2>1</kspace_encoding_step_2>
+      </accelerationFactor>
+      <multiband>
+        <spacing>
+          <dZ>10</dZ>
+          <dZ>20</dZ>
+        </spacing>
+        <deltaKz>0.5</deltaKz>
+        <multiband_factor>3</multiband_factor>
+        <calibration>separable2D</calibration>
+        <calibration_encoding>1</calibration_encoding>
+      </multiband>
+    </parallelImaging>
+    <echoTrainLength>16</echoTrainLength>
+  </encoding>
+  <sequenceParameters>
+    <TR>5</TR>
+    <sequence_type>Gradient Echo</sequence_type>
+    <echo_spacing>0.7</echo_spacing>
+    <diffusionDimension>average</diffusionDimension>
+    <diffusion>
+      <gradientDirection>
+        <rl>1</rl>
+        <ap>0</ap>
+        <fh>0</fh>
+      </gradientDirection>
+      <bvalue>1000</bvalue>
+    </diffusion>
+    <diffusionScheme>monopolar</diffusionScheme>
+  </sequenceParameters>
+</ismrmrdHeader>`
+
+func TestSchemaCoverage(t *testing.T) {
+	head, err := Deserialize([]byte(modernXML))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if head.SubjectInformation.PatientHeightM == nil || *head.SubjectInformation.PatientHeightM != 1.8 ||
+		head.StudyInformation.BodyPartExamined != "HEAD" ||
+		head.MeasurementInformation.RelativeTablePosition == nil ||
+		head.MeasurementInformation.RelativeTablePosition.Z != -25.5 ||
+		head.MeasurementInformation.SequenceName != "gre" {
+		t.Fatal("subject, study or measurement fields were not read")
+	}
+	sys := head.AcquisitionSystemInformation
+	if len(sys.CoilLabel) != 2 || sys.CoilLabel[1] != (CoilLabel{2, "H2"}) || sys.DeviceID != "12345" {
+		t.Fatalf("acquisition system fields were not read (%+v)", sys)
+	}
+
+	e := head.Encoding[0]
+	if e.EchoTrainLength == nil || *e.EchoTrainLength != 16 || e.EncodingLimits.User3 == nil || e.EncodingLimits.User3.Maximum != 7 {
+		t.Fatalf("encoding fields were not read (%+v)", e)
+	}
+	if e.TrajectoryDescription == nil || e.TrajectoryDescription.Identifier != "HargreavesVDS2000" ||
+		len(e.TrajectoryDescription.UserParameterString) != 1 {
+		t.Fatalf("trajectory description was not read (%+v)", e.TrajectoryDescription)
+	}
+	mb := e.ParallelImaging.Multiband
+	if mb == nil || len(mb.Spacing) != 1 || len(mb.Spacing[0].DZ) != 2 ||
+		mb.MultibandFactor != 3 || mb.Calibration != MultibandCalibrationSeparable2D {
+		t.Fatalf("multiband was not read (%+v)", mb)
+	}
+
+	seq := head.SequenceParameters
+	if len(seq.TR) != 1 || seq.SequenceType != "Gradient Echo" || len(seq.EchoSpacing) != 1 ||
+		seq.DiffusionDimension != "average" || seq.DiffusionScheme != "monopolar" ||
+		len(seq.Diffusion) != 1 || seq.Diffusion[0].GradientDirection.RL != 1 || seq.Diffusion[0].Bvalue != 1000 {
+		t.Fatalf("sequence parameters were not read (%+v)", seq)
+	}
+
+	b, err := Serialize(head)
+	if err != nil {
+		t.Fatal(err)
+	}
+	again, err := Deserialize(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !equal(head, again) {
+		t.Fatal("header changed after a round trip")
+	}
+	if again.Encoding[0].ParallelImaging.Multiband.Spacing[0].DZ[1] != 20 ||
+		again.SequenceParameters.Diffusion[0].Bvalue != 1000 {
+		t.Fatal("multiband or diffusion changed after a round trip")
+	}
+}
+
+func TestSerializeOmitsAbsentOptionalElements(t *testing.T) {
+	head := &IsmrmrdHeader{
+		SubjectInformation: &SubjectInformation{PatientID: patientID},
+		StudyInformation:   &StudyInformation{StudyDate: studyDate},
+		MeasurementInformation: &MeasurementInformation{
+			PatientPosition: patientPosition,
+		},
+		AcquisitionSystemInformation: &AcquisitionSystemInformation{
+			SystemVendor: systemVendor,
+		},
+		Encoding: []Encoding{{
+			Trajectory:      "cartesian",
+			ParallelImaging: &ParallelImaging{},
+		}},
+	}
+	b, err := Serialize(head)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{
+		"version", "patientName", "patientBirthdate", "patientGender", "patientWeight_kg",
+		"studyTime", "accessionNumber", "referringPhysicianName",
+		"initialSeriesNumber", "referencedImageSequence",
+		"receiverChannels", "institutionName", "stationName",
+		"echoTrainLength", "calibrationMode", "interleavingDimension",
+	} {
+		if strings.Contains(string(b), "<"+name+">") || strings.Contains(string(b), "<"+name+"/>") {
+			t.Fatalf("absent %s was serialized:\n%s", name, b)
+		}
+	}
+	for _, name := range []string{"patientID", "studyDate", "systemVendor", "accelerationFactor"} {
+		if !strings.Contains(string(b), "<"+name+">") {
+			t.Fatalf("%s was not serialized:\n%s", name, b)
+		}
+	}
+}
+
+func TestSerializeKeepsExplicitZeros(t *testing.T) {
+	in := `<ismrmrdHeader xmlns="http://www.ismrm.org/ISMRMRD">
+  <version>0</version>
+  <subjectInformation>
+    <patientWeight_kg>0</patientWeight_kg>
+  </subjectInformation>
+  <studyInformation>
+    <accessionNumber>0</accessionNumber>
+  </studyInformation>
+  <acquisitionSystemInformation>
+    <relativeReceiverNoiseBandwidth>0</relativeReceiverNoiseBandwidth>
+  </acquisitionSystemInformation>
+  <experimentalConditions>
+    <H1resonanceFrequency_Hz>63500000</H1resonanceFrequency_Hz>
+  </experimentalConditions>
+  <encoding>
+    <trajectory>cartesian</trajectory>
+    <echoTrainLength>0</echoTrainLength>
+  </encoding>
+</ismrmrdHeader>`
+	head, err := Deserialize([]byte(in))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := Serialize(head)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{
+		"version", "patientWeight_kg", "accessionNumber",
+		"relativeReceiverNoiseBandwidth", "echoTrainLength",
+	} {
+		if !strings.Contains(string(b), "<"+name+">0</"+name+">") {
+			t.Fatalf("explicit zero %s was dropped:\n%s", name, b)
+		}
+	}
+}